@@ -0,0 +1,180 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+package apisec
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/DataDog/appsec-internal-go/apisec/internal/timed"
+)
+
+type (
+	// FeedbackSampler is a [Sampler] that adjusts its decisions based on the
+	// outcome of the processing of the requests it sampled in.
+	FeedbackSampler interface {
+		Sampler
+		// Feedback reports the fingerprint of the schema extracted from a
+		// request with the given key that was sampled in.
+		Feedback(key SamplingKey, schemaHash uint64)
+	}
+
+	adaptiveSampler struct {
+		// levels are the samplers of each interval, from the shortest to the
+		// longest, each one twice as long as the previous one.
+		levels []*timed.LRU
+		// states are the adaptive states of the keys (see [adaptiveState]),
+		// indexed by the hash of the keys. Keys whose hashes collide share the
+		// same slot, the last one to report feedback evicting the others.
+		states []atomic.Uint64
+	}
+
+	// adaptiveState is the adaptive state of a key, packed as a 64-bit value:
+	//
+	//	| 63..48 | 47..8       | 7       | 6..0  |
+	//	| tag    | fingerprint | present | level |
+	//
+	// The tag is the top 16 bits of the key hash, used to tell apart the keys
+	// sharing the same slot, and the fingerprint is the low 40 bits of the
+	// schema hash last reported for the key.
+	adaptiveState uint64
+)
+
+const (
+	adaptiveStateLevelMask        adaptiveState = 0x7f
+	adaptiveStatePresentFlag      adaptiveState = 0x80
+	adaptiveStateTagShift                       = 48
+	adaptiveStateFingerprintShift               = 8
+	adaptiveStateFingerprintMask                = 1<<40 - 1
+)
+
+// NewAdaptiveSampler returns a new [FeedbackSampler] that samples each key
+// once per interval, starting at minInterval. Every time the same schema is
+// reported for a key through [FeedbackSampler.Feedback], its interval is
+// doubled, up to maxInterval. As soon as a different schema is reported, its
+// interval is reset to minInterval. Keys are tracked by samplers with the given
+// options. When minInterval is not strictly positive, it cannot be doubled, so
+// every request is sampled in.
+func NewAdaptiveSampler(minInterval, maxInterval time.Duration, opts ...SamplerOption) FeedbackSampler {
+	return newAdaptiveSampler(minInterval, maxInterval, nil, opts...)
+}
+
+// newAdaptiveSampler allows creating a new adaptive sampler with a custom clock
+// function, which is useful for testing.
+func newAdaptiveSampler(minInterval, maxInterval time.Duration, clock clockFunc, opts ...SamplerOption) *adaptiveSampler {
+	minInterval = max(minInterval, 0)
+	maxInterval = max(minInterval, maxInterval)
+
+	var levels []*timed.LRU
+	for interval := minInterval; ; {
+		levels = append(levels, (*timed.LRU)(newSampler(interval, clock, opts...).(*timedSetSampler)))
+		if interval <= 0 || interval >= maxInterval || len(levels) > int(adaptiveStateLevelMask) {
+			break
+		}
+		if interval > maxInterval/2 {
+			interval = maxInterval
+		} else {
+			interval *= 2
+		}
+	}
+
+	cfg := newSamplerConfig(minInterval, opts)
+	return &adaptiveSampler{
+		levels: levels,
+		states: make([]atomic.Uint64, cfg.capacity),
+	}
+}
+
+// DecisionFor makes a sampling decision for the provided [SamplingKey], using
+// its current interval.
+func (s *adaptiveSampler) DecisionFor(key SamplingKey) bool {
+	keyHash := key.Hash()
+	state := s.stateOf(keyHash)
+	return s.levels[state.Level()].Hit(keyHash)
+}
+
+// Feedback reports the fingerprint of the schema extracted from a request with
+// the given key that was sampled in, lengthening its interval if its schema is
+// unchanged, or resetting it to the shortest one otherwise.
+func (s *adaptiveSampler) Feedback(key SamplingKey, schemaHash uint64) {
+	keyHash := key.Hash()
+	slot := &s.states[keyHash%uint64(len(s.states))]
+	for {
+		old := adaptiveState(slot.Load())
+		level, newLevel := 0, 0
+		if old.Matches(keyHash) {
+			level = old.Level()
+			if old.Fingerprint() == schemaHash&adaptiveStateFingerprintMask {
+				newLevel = min(level+1, len(s.levels)-1)
+			}
+		}
+		if !slot.CompareAndSwap(uint64(old), uint64(newAdaptiveState(keyHash, schemaHash, newLevel))) {
+			// Another goroutine has concurrently reported feedback, try again...
+			continue
+		}
+		if newLevel != level {
+			// The request was sampled in by the sampler of the previous level, so
+			// we record it in the sampler of the new level as well.
+			s.levels[newLevel].Touch(keyHash)
+		}
+		return
+	}
+}
+
+// Stats returns the statistics of this sampler, summed over the samplers of
+// all its intervals. Keys whose interval changed may be counted in the live
+// entries of several samplers.
+func (s *adaptiveSampler) Stats() SamplerStats {
+	var stats SamplerStats
+	for _, level := range s.levels {
+		other := (*timedSetSampler)(level).Stats()
+		stats.Keeps += other.Keeps
+		stats.Drops += other.Drops
+		stats.OverflowDrops += other.OverflowDrops
+		stats.Rebuilds += other.Rebuilds
+		stats.LiveEntries += other.LiveEntries
+	}
+	return stats
+}
+
+// Close releases the resources held by this sampler, of which there are none.
+func (s *adaptiveSampler) Close() error {
+	return nil
+}
+
+// stateOf returns the adaptive state of the key with the given hash, or its
+// zero value if none was recorded.
+func (s *adaptiveSampler) stateOf(keyHash uint64) adaptiveState {
+	state := adaptiveState(s.states[keyHash%uint64(len(s.states))].Load())
+	if !state.Matches(keyHash) {
+		return 0
+	}
+	return state
+}
+
+// newAdaptiveState returns the adaptive state of the key with the given hash.
+func newAdaptiveState(keyHash uint64, schemaHash uint64, level int) adaptiveState {
+	return adaptiveState(keyHash>>adaptiveStateTagShift)<<adaptiveStateTagShift |
+		adaptiveState(schemaHash&adaptiveStateFingerprintMask)<<adaptiveStateFingerprintShift |
+		adaptiveStatePresentFlag |
+		adaptiveState(level)&adaptiveStateLevelMask
+}
+
+// Matches returns true if this state is present, and was recorded for the key
+// with the given hash (or one sharing the same tag).
+func (s adaptiveState) Matches(keyHash uint64) bool {
+	return s&adaptiveStatePresentFlag != 0 && uint64(s)>>adaptiveStateTagShift == keyHash>>adaptiveStateTagShift
+}
+
+// Level returns the index of the interval of this state.
+func (s adaptiveState) Level() int {
+	return int(s & adaptiveStateLevelMask)
+}
+
+// Fingerprint returns the schema fingerprint of this state.
+func (s adaptiveState) Fingerprint() uint64 {
+	return uint64(s>>adaptiveStateFingerprintShift) & adaptiveStateFingerprintMask
+}
@@ -0,0 +1,116 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+package apisec
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdaptiveSampler(t *testing.T) {
+	var (
+		users  = SamplingKey{Method: http.MethodGet, Route: "/users", StatusCode: http.StatusOK}
+		orders = SamplingKey{Method: http.MethodGet, Route: "/orders", StatusCode: http.StatusOK}
+	)
+	const (
+		schemaA uint64 = 0xdeadbeef
+		schemaB uint64 = 0xcafebabe
+	)
+
+	t.Run("levels", func(t *testing.T) {
+		clock := func() int64 { return 0 }
+		// 10s, 20s, 40s and 50s
+		require.Len(t, newAdaptiveSampler(10*time.Second, 50*time.Second, clock).levels, 4)
+		// The maximum interval is at least the minimum one
+		require.Len(t, newAdaptiveSampler(time.Minute, time.Second, clock).levels, 1)
+		// A non-positive minimum interval cannot be doubled
+		require.Len(t, newAdaptiveSampler(0, time.Minute, clock).levels, 1)
+		subject := newAdaptiveSampler(-time.Second, time.Minute, clock)
+		require.Len(t, subject.levels, 1)
+		require.True(t, subject.DecisionFor(users))
+		subject.Feedback(users, schemaA)
+		require.True(t, subject.DecisionFor(users))
+	})
+
+	t.Run("feedback", func(t *testing.T) {
+		fakeTime := time.Now().Unix()
+		clock := func() int64 { return fakeTime }
+		subject := newAdaptiveSampler(10*time.Second, 40*time.Second, clock)
+
+		// The interval starts at 10s
+		require.True(t, subject.DecisionFor(users))
+		subject.Feedback(users, schemaA)
+		fakeTime += 9
+		require.False(t, subject.DecisionFor(users))
+		fakeTime++
+		require.True(t, subject.DecisionFor(users))
+
+		// The schema is unchanged, so the interval grows to 20s
+		subject.Feedback(users, schemaA)
+		fakeTime += 19
+		require.False(t, subject.DecisionFor(users))
+		fakeTime++
+		require.True(t, subject.DecisionFor(users))
+
+		// ...then 40s, which is the maximum
+		for range 3 {
+			subject.Feedback(users, schemaA)
+			fakeTime += 39
+			require.False(t, subject.DecisionFor(users))
+			fakeTime++
+			require.True(t, subject.DecisionFor(users))
+		}
+
+		// Other keys are unaffected
+		require.True(t, subject.DecisionFor(orders))
+		fakeTime += 10
+		require.True(t, subject.DecisionFor(orders))
+
+		// The schema changed, so the interval is reset to 10s
+		subject.Feedback(users, schemaB)
+		fakeTime += 9
+		require.False(t, subject.DecisionFor(users))
+		fakeTime++
+		require.True(t, subject.DecisionFor(users))
+
+		stats := subject.Stats()
+		assert.Equal(t, uint64(9), stats.Keeps)
+		assert.Equal(t, uint64(6), stats.Drops)
+	})
+
+	t.Run("collision", func(t *testing.T) {
+		fakeTime := time.Now().Unix()
+		clock := func() int64 { return fakeTime }
+		// A single state slot is shared by all keys
+		subject := newAdaptiveSampler(10*time.Second, 40*time.Second, clock, WithCapacity(1))
+
+		require.True(t, subject.DecisionFor(users))
+		subject.Feedback(users, schemaA)
+		subject.Feedback(users, schemaA)
+		require.Equal(t, 1, subject.stateOf(users.Hash()).Level())
+
+		// The state of another key evicts the one of users
+		subject.Feedback(orders, schemaA)
+		require.Equal(t, 0, subject.stateOf(users.Hash()).Level())
+		require.Equal(t, 0, subject.stateOf(orders.Hash()).Level())
+	})
+}
+
+func TestAdaptiveState(t *testing.T) {
+	const keyHash uint64 = 0x1234_5678_9abc_def0
+	state := newAdaptiveState(keyHash, 0xffff_0123_4567_89ab, 42)
+	require.True(t, state.Matches(keyHash))
+	require.True(t, state.Matches(0x1234_0000_0000_0000))
+	require.False(t, state.Matches(0x4321_5678_9abc_def0))
+	require.Equal(t, 42, state.Level())
+	require.Equal(t, uint64(0x23_4567_89ab), state.Fingerprint())
+
+	require.False(t, adaptiveState(0).Matches(0))
+}
@@ -0,0 +1,163 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+package apisec
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+type (
+	// SamplingPredicate reports whether a [SamplingKey] matches some criteria.
+	SamplingPredicate func(SamplingKey) bool
+
+	allSampler struct {
+		decisionCounter
+		samplers []Sampler
+	}
+
+	anySampler struct {
+		decisionCounter
+		samplers []Sampler
+	}
+
+	notSampler struct {
+		decisionCounter
+		sampler Sampler
+	}
+
+	predicateSampler struct {
+		decisionCounter
+		predicate SamplingPredicate
+		// keep is the decision made for the keys matching the predicate; the
+		// opposite decision is made for the others.
+		keep bool
+	}
+
+	// decisionCounter keeps track of the decisions made by a combinator.
+	decisionCounter struct {
+		keeps atomic.Uint64
+		drops atomic.Uint64
+	}
+)
+
+// All returns a [Sampler] that keeps a key only if all the given samplers keep
+// it. The samplers are consulted in order, and the first DROP decision is
+// final, so that the following samplers are not consulted. As the samplers
+// consulted before the first DROP decision may have recorded a KEEP decision,
+// stateless or rate-limiting samplers should usually come last, so that they
+// are only consulted for keys the others kept. With no samplers, all keys are
+// kept.
+func All(samplers ...Sampler) Sampler {
+	return &allSampler{samplers: samplers}
+}
+
+// Any returns a [Sampler] that keeps a key if any of the given samplers keeps
+// it. The samplers are consulted in order, and the first KEEP decision is
+// final, so that the following samplers are not consulted. With no samplers,
+// all keys are dropped.
+func Any(samplers ...Sampler) Sampler {
+	if len(samplers) == 0 {
+		return &nullSampler{}
+	}
+	return &anySampler{samplers: samplers}
+}
+
+// Not returns a [Sampler] that keeps the keys dropped by the given sampler, and
+// drops the keys it keeps.
+func Not(sampler Sampler) Sampler {
+	return &notSampler{sampler: sampler}
+}
+
+// ForceKeep returns a [Sampler] that keeps the keys matching the given
+// predicate, and drops all others. Combined with [Any], it forces keeping an
+// allow-list of keys regardless of other samplers.
+func ForceKeep(predicate SamplingPredicate) Sampler {
+	return &predicateSampler{predicate: predicate, keep: true}
+}
+
+// ForceDrop returns a [Sampler] that drops the keys matching the given
+// predicate, and keeps all others. Combined with [All], it forces dropping a
+// deny-list of keys regardless of other samplers.
+func ForceDrop(predicate SamplingPredicate) Sampler {
+	return &predicateSampler{predicate: predicate, keep: false}
+}
+
+// DecisionFor keeps the provided [SamplingKey] if all the samplers keep it.
+func (s *allSampler) DecisionFor(key SamplingKey) bool {
+	for _, sampler := range s.samplers {
+		if !sampler.DecisionFor(key) {
+			return s.record(false)
+		}
+	}
+	return s.record(true)
+}
+
+// DecisionFor keeps the provided [SamplingKey] if any of the samplers keeps it.
+func (s *anySampler) DecisionFor(key SamplingKey) bool {
+	for _, sampler := range s.samplers {
+		if sampler.DecisionFor(key) {
+			return s.record(true)
+		}
+	}
+	return s.record(false)
+}
+
+// DecisionFor keeps the provided [SamplingKey] if the sampler drops it.
+func (s *notSampler) DecisionFor(key SamplingKey) bool {
+	return s.record(!s.sampler.DecisionFor(key))
+}
+
+// DecisionFor makes a sampling decision for the provided [SamplingKey] based
+// on whether it matches the predicate.
+func (s *predicateSampler) DecisionFor(key SamplingKey) bool {
+	return s.record(s.predicate(key) == s.keep)
+}
+
+// Close releases the resources held by all the samplers.
+func (s *allSampler) Close() error {
+	return closeSamplers(s.samplers...)
+}
+
+// Close releases the resources held by all the samplers.
+func (s *anySampler) Close() error {
+	return closeSamplers(s.samplers...)
+}
+
+// Close releases the resources held by the sampler.
+func (s *notSampler) Close() error {
+	return CloseSampler(s.sampler)
+}
+
+// Close releases the resources held by this sampler, of which there are none.
+func (s *predicateSampler) Close() error {
+	return nil
+}
+
+// closeSamplers releases the resources held by all the given samplers.
+func closeSamplers(samplers ...Sampler) error {
+	errs := make([]error, len(samplers))
+	for i, sampler := range samplers {
+		errs[i] = CloseSampler(sampler)
+	}
+	return errors.Join(errs...)
+}
+
+// record counts the given decision, and returns it.
+func (c *decisionCounter) record(keep bool) bool {
+	if keep {
+		c.keeps.Add(1)
+	} else {
+		c.drops.Add(1)
+	}
+	return keep
+}
+
+// Stats returns the statistics of the decisions made by this combinator. The
+// statistics of the combined samplers can be obtained from them directly.
+func (c *decisionCounter) Stats() SamplerStats {
+	return SamplerStats{Keeps: c.keeps.Load(), Drops: c.drops.Load()}
+}
@@ -0,0 +1,95 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+package apisec
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCombinators(t *testing.T) {
+	var (
+		users  = SamplingKey{Method: http.MethodGet, Route: "/users", StatusCode: http.StatusOK}
+		admin  = SamplingKey{Method: http.MethodGet, Route: "/admin/users", StatusCode: http.StatusOK}
+		health = SamplingKey{Method: http.MethodGet, Route: "/health", StatusCode: http.StatusOK}
+	)
+	isAdmin := func(key SamplingKey) bool { return strings.HasPrefix(key.Route, "/admin/") }
+	isHealth := func(key SamplingKey) bool { return key.Route == "/health" }
+
+	keep := ForceKeep(func(SamplingKey) bool { return true })
+	drop := ForceDrop(func(SamplingKey) bool { return true })
+
+	t.Run("All", func(t *testing.T) {
+		require.True(t, All().DecisionFor(users))
+		require.True(t, All(keep, keep).DecisionFor(users))
+		require.False(t, All(keep, drop).DecisionFor(users))
+
+		// The samplers following a DROP decision are not consulted
+		fakeTime := time.Now().Unix()
+		timed := newSampler(30*time.Second, func() int64 { return fakeTime })
+		subject := All(drop, timed)
+		require.False(t, subject.DecisionFor(users))
+		require.True(t, timed.DecisionFor(users))
+
+		stats, ok := StatsOf(subject)
+		require.True(t, ok)
+		require.Equal(t, SamplerStats{Drops: 1}, stats)
+	})
+
+	t.Run("Any", func(t *testing.T) {
+		require.False(t, Any().DecisionFor(users))
+		require.True(t, Any(drop, keep).DecisionFor(users))
+		require.False(t, Any(drop, drop).DecisionFor(users))
+
+		// The samplers following a KEEP decision are not consulted
+		fakeTime := time.Now().Unix()
+		timed := newSampler(30*time.Second, func() int64 { return fakeTime })
+		subject := Any(keep, timed)
+		require.True(t, subject.DecisionFor(users))
+		require.True(t, timed.DecisionFor(users))
+	})
+
+	t.Run("Not", func(t *testing.T) {
+		require.False(t, Not(keep).DecisionFor(users))
+		require.True(t, Not(drop).DecisionFor(users))
+
+		subject := Not(ForceKeep(isHealth))
+		require.True(t, subject.DecisionFor(users))
+		require.False(t, subject.DecisionFor(health))
+		stats, ok := StatsOf(subject)
+		require.True(t, ok)
+		require.Equal(t, SamplerStats{Keeps: 1, Drops: 1}, stats)
+	})
+
+	t.Run("policy", func(t *testing.T) {
+		fakeTime := time.Now().Unix()
+		timed := newSampler(30*time.Second, func() int64 { return fakeTime })
+
+		// Always keep admin routes, never keep health checks, and sample the
+		// others once per interval.
+		subject := Any(ForceKeep(isAdmin), All(ForceDrop(isHealth), timed))
+		for range 3 {
+			require.True(t, subject.DecisionFor(admin))
+			require.False(t, subject.DecisionFor(health))
+		}
+		require.True(t, subject.DecisionFor(users))
+		require.False(t, subject.DecisionFor(users))
+		fakeTime += 30
+		require.True(t, subject.DecisionFor(users))
+
+		stats, ok := StatsOf(subject)
+		require.True(t, ok)
+		require.Equal(t, SamplerStats{Keeps: 5, Drops: 4}, stats)
+
+		timedStats, ok := StatsOf(timed)
+		require.True(t, ok)
+		require.Equal(t, SamplerStats{Keeps: 2, Drops: 1, LiveEntries: 1}, timedStats)
+	})
+}
@@ -6,6 +6,10 @@
 package config
 
 const (
-	// MaxItemCount is the maximum amount of items to keep in a timed set.
+	// MaxItemCount is the default maximum amount of items to keep in a timed
+	// set.
 	MaxItemCount = 4_096
+	// MaxItemCountLimit is the largest supported maximum amount of items to
+	// keep in a timed set, as the item count of its table is stored on 32 bits.
+	MaxItemCountLimit = 1 << 24
 )
@@ -13,7 +13,8 @@ type (
 	// biasedClock is a specialized clock implementation used to ensure we can get
 	// 32-bit wide timestamps without having to worry about wraparound.
 	biasedClock struct {
-		// clock is the underlying clock, returning a timestamp in seconds.
+		// clock is the underlying clock, returning a timestamp in seconds or
+		// milliseconds.
 		clock ClockFunc
 		// bias is effectively the time at which the biasedClock was initialized.
 		bias int64
@@ -29,7 +30,9 @@ func newBiasedClock(clock ClockFunc, horizon uint32) biasedClock {
 	}
 }
 
-// Now returns the current timestamp, relative to this [biasedClock].
+// Now returns the current timestamp, relative to this [biasedClock]. It wraps
+// around once it exceeds [math.MaxUint32], which takes about 49.7 days with
+// millisecond timestamps.
 func (c *biasedClock) Now() uint32 {
 	// We clamp it to [0,) to be absolutely safe...
 	return uint32(max(0, c.clock()-c.bias))
@@ -40,3 +43,9 @@ func (c *biasedClock) Now() uint32 {
 func UnixTime() int64 {
 	return time.Now().Unix()
 }
+
+// UnixMilliTime is a [ClockFunc] that returns the current Unix time in
+// milliseconds, for use with [time.Millisecond] resolution.
+func UnixMilliTime() int64 {
+	return time.Now().UnixMilli()
+}
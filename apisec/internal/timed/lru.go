@@ -16,12 +16,6 @@ import (
 	"github.com/DataDog/appsec-internal-go/log"
 )
 
-// capacity is the maximum number of items that may be temporarily present in a
-// [LRU]. An eviction triggers once [config.MaxItemCount] is reached, however the
-// implementation is based on Copy-Update-Replace semantics, so during a table
-// rebuild, the old table may contrinue to receive items for a short while.
-const capacity = 2 * config.MaxItemCount
-
 // LRU is a specialized open-addressing-hash-table-based implementation of a
 // specialized LRU cache, using Copy-Update-Replace semantics to operate in a
 // completely lock-less manner.
@@ -31,9 +25,12 @@ type LRU struct {
 	// clock is used to determine the current timestamp when making
 	// changes
 	clock biasedClock
-	// intervalSeconds is the amount of time in seconds that an entry is
-	// considered live for.
-	intervalSeconds uint32
+	// interval is the amount of time in clock units (seconds or milliseconds,
+	// depending on the resolution) that an entry is considered live for.
+	interval uint32
+	// resolution is the duration of a clock unit, either [time.Second] or
+	// [time.Millisecond].
+	resolution time.Duration
 	// zeroKey is a key that is used to replace 0 in the set. This key and 0 are
 	// effectively the same item. This allows us to gracefully handle 0 in our
 	// use-case without having to half the hash-space (to 63 bits) so we can use
@@ -44,28 +41,117 @@ type LRU struct {
 	// rebuilding is a flag to indicate whether the table is being rebuilt as
 	// part of an eviction request.
 	rebuilding atomic.Bool
+	// maxItemCount is the number of items past which an eviction triggers.
+	maxItemCount int32
+	// capacity is the maximum number of items that may be temporarily present
+	// in the table. An eviction triggers once maxItemCount is reached, however
+	// the implementation is based on Copy-Update-Replace semantics, so during a
+	// table rebuild, the old table may continue to receive items for a short
+	// while.
+	capacity int32
+
+	// _ keeps the counters below, which are updated on every [LRU.Hit] call,
+	// off the cache line of the fields above, which are read on every call.
+	_ [64]byte
+	// keeps is the number of [LRU.Hit] calls that resulted in a KEEP decision.
+	keeps atomic.Uint64
+	// drops is the number of [LRU.Hit] calls that resulted in a DROP decision,
+	// including overflowDrops.
+	drops atomic.Uint64
+	// overflowDrops is the number of [LRU.Hit] calls that resulted in a DROP
+	// decision because the table was full.
+	overflowDrops atomic.Uint64
+	// rebuilds is the number of completed table rebuilds.
+	rebuilds atomic.Uint64
+}
+
+// Stats is a snapshot of the counters of a [LRU].
+type Stats struct {
+	// Keeps is the number of [LRU.Hit] calls that resulted in a KEEP decision.
+	Keeps uint64
+	// Drops is the number of [LRU.Hit] calls that resulted in a DROP decision,
+	// including OverflowDrops.
+	Drops uint64
+	// OverflowDrops is the number of [LRU.Hit] calls that resulted in a DROP
+	// decision because the table was full, while waiting for a rebuild to
+	// complete.
+	OverflowDrops uint64
+	// Rebuilds is the number of completed table rebuilds (eviction passes).
+	Rebuilds uint64
+	// LiveEntries is the number of entries currently held in the table, some
+	// of which may have expired but not been evicted yet.
+	LiveEntries int
+}
+
+// Option is an optional setting of a [LRU].
+type Option func(*options)
+
+// options holds the optional settings of a [LRU].
+type options struct {
+	maxItemCount int
+	resolution   time.Duration
+}
+
+// WithMaxItemCount sets the number of items past which the [LRU] evicts its
+// least recently sampled items. It defaults to [config.MaxItemCount].
+func WithMaxItemCount(maxItemCount int) Option {
+	return func(o *options) {
+		o.maxItemCount = maxItemCount
+	}
+}
+
+// WithResolution sets the resolution of the timestamps stored by the [LRU],
+// either [time.Second] (the default) or [time.Millisecond]. The clock function
+// given to [NewLRU] must return timestamps in this unit, such as [UnixTime] or
+// [UnixMilliTime].
+func WithResolution(resolution time.Duration) Option {
+	return func(o *options) {
+		o.resolution = resolution
+	}
 }
 
 // NewLRU initializes a new, empty [LRU] with the given interval and clock
-// function. A warning will be logged if it is set below 1 second. Panics if
-// the interval is more than [math.MaxUint32] seconds, as this value cannot be
-// used internally.
+// function. A warning will be logged if it is set below the resolution of the
+// timestamps (1 second by default). Panics if the interval is more than
+// [math.MaxUint32] time units, as this value cannot be used internally, if the
+// resolution is not supported, or if the maximum item count is not in the
+// (0, [config.MaxItemCountLimit]] range.
 //
-// Note: timestamps are stored at second resolution, so the interval will be
-// rounded down to the nearest second.
-func NewLRU(interval time.Duration, clock ClockFunc) *LRU {
-	if interval < time.Second {
-		log.Warn("NewLRU: interval is less than one second; this should not be attempted in production (value: %s)", interval)
+// Note: timestamps are stored at second resolution by default, so the interval
+// will be rounded down to the nearest second. Intervals longer than
+// [math.MaxInt32] time units (about 68 years, or 24.8 days at millisecond
+// resolution) are reduced to that value, so that timestamps can wrap around.
+func NewLRU(interval time.Duration, clock ClockFunc, opts ...Option) *LRU {
+	cfg := options{maxItemCount: config.MaxItemCount, resolution: time.Second}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	if cfg.resolution != time.Second && cfg.resolution != time.Millisecond {
+		panic(fmt.Errorf("NewLRU: resolution must be %s or %s, but was %s", time.Second, time.Millisecond, cfg.resolution))
+	}
+	if interval < cfg.resolution {
+		if cfg.resolution == time.Second {
+			log.Warn("NewLRU: interval is less than one second; this should not be attempted in production (value: %s)", interval)
+		} else {
+			log.Warn("NewLRU: interval is less than one millisecond; this should not be attempted in production (value: %s)", interval)
+		}
 	}
-	if interval > time.Second*math.MaxUint32 {
-		panic(fmt.Errorf("NewLRU: interval must be <= %s, but was %s", time.Second*math.MaxUint32, interval))
+	if interval > cfg.resolution*math.MaxUint32 {
+		panic(fmt.Errorf("NewLRU: interval must be <= %s, but was %s", cfg.resolution*math.MaxUint32, interval))
+	}
+	if cfg.maxItemCount <= 0 || cfg.maxItemCount > config.MaxItemCountLimit {
+		panic(fmt.Errorf("NewLRU: max item count must be in (0, %d], but was %d", config.MaxItemCountLimit, cfg.maxItemCount))
 	}
 
-	intervalSeconds := uint32(interval.Seconds())
+	intervalUnits := uint32(min(interval/cfg.resolution, math.MaxInt32))
 	set := &LRU{
-		clock:           newBiasedClock(clock, intervalSeconds),
-		intervalSeconds: intervalSeconds,
-		zeroKey:         rand.Uint64(),
+		clock:        newBiasedClock(clock, intervalUnits),
+		interval:     intervalUnits,
+		resolution:   cfg.resolution,
+		zeroKey:      rand.Uint64(),
+		maxItemCount: int32(cfg.maxItemCount),
+		capacity:     int32(2 * cfg.maxItemCount),
 	}
 
 	// That value cannot be zero...
@@ -73,14 +159,14 @@ func NewLRU(interval time.Duration, clock ClockFunc) *LRU {
 		set.zeroKey = rand.Uint64()
 	}
 
-	set.table.Store(&table{})
+	set.table.Store(newTable(int(set.capacity)))
 
 	return set
 }
 
 // Hit determines whether the given key should be kept or dropped based on the
-// last time it was sampled. If the table grows larger than [config.MaxItemCount], the
-// [LRU.rebuild] method is called in a separate goroutine to begin the
+// last time it was sampled. If the table grows larger than [LRU.maxItemCount],
+// the [LRU.rebuild] method is called in a separate goroutine to begin the
 // eviction process. Until this has completed, all updates to the [LRU] are
 // effectively dropped, as they happen on the soon-to-be-replaced table.
 //
@@ -91,6 +177,37 @@ func NewLRU(interval time.Duration, clock ClockFunc) *LRU {
 // common use, as given a uniform distribution of keys this only happens 1 in
 // 2^64-1 times.
 func (m *LRU) Hit(key uint64) bool {
+	if m.hit(key) {
+		m.keeps.Add(1)
+		return true
+	}
+	m.drops.Add(1)
+	return false
+}
+
+// Touch records the given key as sampled now, unless it was already sampled
+// within the interval. Unlike [LRU.Hit], it does not count as a sampling
+// decision in the [LRU.Stats].
+func (m *LRU) Touch(key uint64) {
+	m.hit(key)
+}
+
+// Sampled returns true if the given key was sampled within the interval, that
+// is, if [LRU.Hit] would currently make a DROP decision for it. Unlike
+// [LRU.Hit], it neither records an access nor counts as a sampling decision.
+func (m *LRU) Sampled(key uint64) bool {
+	if key == 0 {
+		key = m.zeroKey
+	}
+	entry, exists := m.table.Load().FindEntry(key)
+	if !exists {
+		return false
+	}
+	return entry.Data.Load().SampleAge(m.clock.Now(), m.interval) < m.interval
+}
+
+// hit implements [LRU.Hit], without updating the keep and drop counters.
+func (m *LRU) hit(key uint64) bool {
 	if key == 0 {
 		// The 0 key is used as a way to imply a slot is empty; so we cannot store
 		// it in the table. To address this, when passed a 0 key, we will use the
@@ -99,7 +216,6 @@ func (m *LRU) Hit(key uint64) bool {
 	}
 
 	now := m.clock.Now()
-	threshold := now - m.intervalSeconds
 
 	var (
 		table = m.table.Load()
@@ -117,19 +233,20 @@ func (m *LRU) Hit(key uint64) bool {
 		// 1. Ensure we have capacity (possibly trigger an eviction rebuild)
 		// 2. Claim the slot (or look for another slot if it's already claimed)
 		newCount := table.count.Add(1)
-		if newCount > config.MaxItemCount && m.rebuilding.CompareAndSwap(false, true) {
+		if newCount > m.maxItemCount && m.rebuilding.CompareAndSwap(false, true) {
 			// We're already holding the maximium number of items, so we will rebuild
 			// in order to perform an eviction pass. Updates made in the meantime will
 			// be lost.
-			go m.rebuild(table, threshold)
+			go m.rebuild(table, now)
 		}
-		if newCount > capacity {
+		if newCount > m.capacity {
 			// We don't have space to add any new item, so we'll ignore this and
 			// decide to DROP it (we may otherwise cause a surge of inconditional
 			// keep decisions, that is not desirable). This only happens in the most
 			// dire of circumstances (a table rebuild did not complete fast enough
 			// to make up free space).
 			table.count.Add(-1)
+			m.overflowDrops.Add(1)
 			return false
 		}
 
@@ -155,7 +272,7 @@ func (m *LRU) Hit(key uint64) bool {
 
 	// We have found an existing entry, so we can proceed to update it...
 	curData := entry.Data.Load()
-	if curData.SampleTime() <= threshold {
+	if curData.SampleAge(now, m.interval) >= m.interval {
 		// We sampled this a while back (or this is the first time), so we may keep
 		// this sample!
 
@@ -171,7 +288,7 @@ func (m *LRU) Hit(key uint64) bool {
 				return false
 			}
 
-			if curData.SampleTime() >= now {
+			if !timeBefore(curData.SampleTime(), now) {
 				// The concurrent update was made in our future, and it somehow was not
 				// a KEEP, so we'll make a KEEP decision here, but avoid rolling back
 				// the [entryData.AccessTime] back.
@@ -187,7 +304,7 @@ func (m *LRU) Hit(key uint64) bool {
 	}
 
 	newData := curData.WithAccessTime(now)
-	for curData.AccessTime() < now {
+	for timeBefore(curData.AccessTime(), now) {
 		if entry.Data.CompareAndSwap(curData, newData) {
 			// We are done here!
 			break
@@ -200,12 +317,26 @@ func (m *LRU) Hit(key uint64) bool {
 
 // rebuild runs in a separate goroutine, and creates a pruned copy of the
 // provided [table] with old and expired entries removed. It will keep at most
-// [config.MaxItemCount]*2/3 items in the new table. Once the rebuild is complete, it
-// replaces the [LRU.table] with the copy.
-func (m *LRU) rebuild(oldTable *table, threshold uint32) {
+// [LRU.maxItemCount]*2/3 items in the new table. Once the rebuild is complete,
+// it replaces the [LRU.table] with the copy.
+func (m *LRU) rebuild(oldTable *table, now uint32) {
 	// Since Go has a GC, we can "just" replace the current [Set.table] with a
 	// trimmed down copy, and let the GC take care of reclaiming the old one, once
 	// it is no longer in use by any reader.
-	m.table.Store(oldTable.PrunedCopy(threshold))
+	m.table.Store(oldTable.PrunedCopy(now, m.interval, m.maxItemCount))
+	m.rebuilds.Add(1)
 	m.rebuilding.Store(false)
 }
+
+// Stats returns a snapshot of the counters of this [LRU]. The counters are
+// read independently from each other, so they may be slightly inconsistent if
+// the [LRU] is concurrently used.
+func (m *LRU) Stats() Stats {
+	return Stats{
+		Keeps:         m.keeps.Load(),
+		Drops:         m.drops.Load(),
+		OverflowDrops: m.overflowDrops.Load(),
+		Rebuilds:      m.rebuilds.Load(),
+		LiveEntries:   int(m.table.Load().count.Load()),
+	}
+}
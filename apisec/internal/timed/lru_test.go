@@ -7,7 +7,9 @@ package timed
 
 import (
 	"context"
+	"fmt"
 	"math"
+	"math/rand/v2"
 	"runtime"
 	"sync"
 	"testing"
@@ -21,6 +23,16 @@ import (
 func TestLRU(t *testing.T) {
 	t.Run("NewLRU", func(t *testing.T) {
 		require.PanicsWithError(t, "NewLRU: interval must be <= 1193046h28m15s, but was 1193046h28m16s", func() { NewLRU(time.Second*(math.MaxUint32+1), UnixTime) })
+		require.PanicsWithError(t, "NewLRU: interval must be <= 1193h2m47.295s, but was 1193h2m47.296s", func() {
+			NewLRU(time.Millisecond*(math.MaxUint32+1), UnixMilliTime, WithResolution(time.Millisecond))
+		})
+		require.PanicsWithError(t, "NewLRU: resolution must be 1s or 1ms, but was 1µs", func() { NewLRU(time.Second, UnixTime, WithResolution(time.Microsecond)) })
+		require.PanicsWithError(t, "NewLRU: max item count must be in (0, 16777216], but was 0", func() { NewLRU(time.Second, UnixTime, WithMaxItemCount(0)) })
+		require.PanicsWithError(t, "NewLRU: max item count must be in (0, 16777216], but was 16777217", func() { NewLRU(time.Second, UnixTime, WithMaxItemCount(config.MaxItemCountLimit+1)) })
+
+		subject := NewLRU(time.Second, UnixTime, WithMaxItemCount(10))
+		require.EqualValues(t, 10, subject.maxItemCount)
+		require.Len(t, subject.table.Load().entries, 21)
 	})
 
 	t.Run("Hit", func(t *testing.T) {
@@ -43,7 +55,7 @@ func TestLRU(t *testing.T) {
 			// Keys are slotted via [% capacity], so if we don't properly encode
 			// 0-values, the new slot will inherit the previously set sample time, and
 			// the assertion will fail as a result.
-			zeroSlot := uint64(capacity)
+			zeroSlot := uint64(subject.capacity)
 			if zeroSlot == subject.zeroKey {
 				// There is a very small chance that the zero key has been set to
 				// [capacity], in which case we'll just double it to escape the
@@ -108,4 +120,179 @@ func TestLRU(t *testing.T) {
 		// We shoudl not have more than [maxItemCount] items left in the map...
 		require.LessOrEqual(t, count, config.MaxItemCount)
 	})
+
+	t.Run("Stats", func(t *testing.T) {
+		fakeTime := time.Now().Unix()
+		fakeClock := func() int64 { return fakeTime }
+		subject := NewLRU(30*time.Second, fakeClock)
+
+		require.True(t, subject.Hit(1337))
+		require.False(t, subject.Hit(1337))
+		require.True(t, subject.Hit(42))
+		require.Equal(t, Stats{Keeps: 2, Drops: 1, LiveEntries: 2}, subject.Stats())
+
+		t.Run("overflow", func(t *testing.T) {
+			// Pretend a rebuild is in progress while the table is full, which
+			// otherwise only happens under heavy concurrent use
+			subject.rebuilding.Store(true)
+			subject.table.Load().count.Store(subject.capacity)
+			require.False(t, subject.Hit(1))
+			require.False(t, subject.Hit(2))
+			require.Equal(t, Stats{Keeps: 2, Drops: 3, OverflowDrops: 2, LiveEntries: int(subject.capacity)}, subject.Stats())
+
+			subject.rebuilding.Store(false)
+			require.False(t, subject.Hit(3))
+			for subject.rebuilding.Load() {
+				runtime.Gosched()
+			}
+			require.Equal(t, Stats{Keeps: 2, Drops: 4, OverflowDrops: 3, Rebuilds: 1, LiveEntries: 2}, subject.Stats())
+		})
+	})
+
+	t.Run("Touch", func(t *testing.T) {
+		fakeTime := time.Now().Unix()
+		fakeClock := func() int64 { return fakeTime }
+		subject := NewLRU(30*time.Second, fakeClock)
+
+		subject.Touch(1337)
+		require.False(t, subject.Hit(1337))
+		fakeTime += 20
+		subject.Touch(1337)
+		fakeTime += 10
+		require.True(t, subject.Hit(1337))
+		require.Equal(t, Stats{Keeps: 1, Drops: 1, LiveEntries: 1}, subject.Stats())
+	})
+
+	t.Run("Sampled", func(t *testing.T) {
+		fakeTime := time.Now().Unix()
+		fakeClock := func() int64 { return fakeTime }
+		subject := NewLRU(30*time.Second, fakeClock)
+
+		require.False(t, subject.Sampled(1337))
+		require.False(t, subject.Sampled(0))
+		require.True(t, subject.Hit(1337))
+		require.True(t, subject.Hit(0))
+		require.True(t, subject.Sampled(1337))
+		require.True(t, subject.Sampled(0))
+		fakeTime += 29
+		require.True(t, subject.Sampled(1337))
+		fakeTime++
+		require.False(t, subject.Sampled(1337))
+		require.Equal(t, Stats{Keeps: 2, LiveEntries: 2}, subject.Stats())
+	})
+
+	t.Run("WithMaxItemCount", func(t *testing.T) {
+		fakeTime := time.Now().Unix()
+		fakeClock := func() int64 { return fakeTime }
+
+		const maxItemCount = 100
+		subject := NewLRU(5*time.Minute, fakeClock, WithMaxItemCount(maxItemCount))
+		for key := range uint64(maxItemCount) {
+			require.True(t, subject.Hit(key+1))
+			fakeTime++
+		}
+		require.Zero(t, subject.Stats().Rebuilds)
+
+		// One more key triggers an eviction pass
+		require.True(t, subject.Hit(maxItemCount+1))
+		for subject.rebuilding.Load() {
+			runtime.Gosched()
+		}
+		stats := subject.Stats()
+		require.EqualValues(t, 1, stats.Rebuilds)
+		require.Equal(t, maxItemCount*2/3, stats.LiveEntries)
+
+		// The most recently sampled keys have been retained
+		require.False(t, subject.Hit(maxItemCount))
+		require.True(t, subject.Hit(1))
+	})
+}
+
+func TestLRUMilliseconds(t *testing.T) {
+	const interval = 250 * time.Millisecond
+
+	t.Run("Hit", func(t *testing.T) {
+		fakeTime := time.Now().UnixMilli()
+		fakeClock := func() int64 { return fakeTime }
+		subject := NewLRU(interval, fakeClock, WithResolution(time.Millisecond))
+
+		require.True(t, subject.Hit(1337))
+		for range interval.Milliseconds() {
+			require.False(t, subject.Hit(1337))
+			fakeTime++
+		}
+		require.True(t, subject.Hit(1337))
+	})
+
+	t.Run("wraparound", func(t *testing.T) {
+		fakeTime := time.Now().UnixMilli()
+		fakeClock := func() int64 { return fakeTime }
+		subject := NewLRU(interval, fakeClock, WithResolution(time.Millisecond))
+
+		// Move right before the biased clock wraps around
+		fakeTime += math.MaxUint32 - int64(subject.clock.Now()) - 100
+		require.True(t, subject.Hit(1337))
+		fakeTime += 200
+		require.Less(t, subject.clock.Now(), uint32(200))
+		require.False(t, subject.Hit(1337))
+		fakeTime += interval.Milliseconds() - 200
+		require.True(t, subject.Hit(1337))
+		require.False(t, subject.Hit(1337))
+	})
+
+	t.Run("long-idle", func(t *testing.T) {
+		fakeTime := time.Now().UnixMilli()
+		fakeClock := func() int64 { return fakeTime }
+		subject := NewLRU(interval, fakeClock, WithResolution(time.Millisecond))
+
+		require.True(t, subject.Hit(1337))
+		// More than 2^31 milliseconds later
+		fakeTime += (30 * 24 * time.Hour).Milliseconds()
+		require.True(t, subject.Hit(1337))
+		require.False(t, subject.Hit(1337))
+	})
+
+	t.Run("rebuild", func(t *testing.T) {
+		fakeTime := time.Now().UnixMilli()
+		fakeClock := func() int64 { return fakeTime }
+
+		const maxItemCount = 30
+		subject := NewLRU(time.Second, fakeClock, WithResolution(time.Millisecond), WithMaxItemCount(maxItemCount))
+		// Straddle the wraparound point of the biased clock
+		fakeTime += math.MaxUint32 - int64(subject.clock.Now()) - maxItemCount/2
+		for key := range uint64(maxItemCount) {
+			require.True(t, subject.Hit(key+1))
+			fakeTime++
+		}
+		require.True(t, subject.Hit(maxItemCount+1))
+		for subject.rebuilding.Load() {
+			runtime.Gosched()
+		}
+
+		// The most recently sampled keys have been retained, despite the wraparound
+		require.EqualValues(t, maxItemCount*2/3, subject.Stats().LiveEntries)
+		require.False(t, subject.Hit(maxItemCount))
+		require.True(t, subject.Hit(1))
+	})
+}
+
+func BenchmarkLRU(b *testing.B) {
+	for _, maxItemCount := range []int{1 << 10, config.MaxItemCount, 1 << 16, 1 << 20} {
+		for _, keySpaceSize := range []int{maxItemCount / 2, maxItemCount * 2} {
+			b.Run(fmt.Sprintf("maxItemCount=%d/keySpaceSize=%d", maxItemCount, keySpaceSize), func(b *testing.B) {
+				subject := NewLRU(time.Second, UnixTime, WithMaxItemCount(maxItemCount))
+				keys := make([]uint64, keySpaceSize)
+				for i := range keys {
+					keys[i] = rand.Uint64()
+				}
+
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					for i := rand.IntN(keySpaceSize); pb.Next(); i = (i + 1) % keySpaceSize {
+						_ = subject.Hit(keys[i])
+					}
+				})
+			})
+		}
+	}
 }
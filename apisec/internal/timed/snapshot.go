@@ -0,0 +1,249 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+package timed
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"time"
+)
+
+// Snapshots are encoded as follows, with all fixed-size integers in
+// little-endian byte order:
+//
+//	magic      [4]byte  "DDLR"
+//	version    uint8    snapshotVersion
+//	length     uint32   length of the payload
+//	payload:
+//	  resolution  uint8    0 for seconds, 1 for milliseconds
+//	  time        varint   clock time at which the snapshot was taken
+//	  count       uvarint  number of entries
+//	  entries     [count]
+//	    key          uint64   key of the entry, 0 standing for [LRU.zeroKey]
+//	    sampleAge    uvarint  time elapsed since the entry was last sampled
+//	    accessDelta  uvarint  time elapsed between the entry's last sample and
+//	                          its last access
+const (
+	// snapshotMagic identifies [LRU] snapshots.
+	snapshotMagic = "DDLR"
+	// snapshotVersion is the version of the snapshot encoding.
+	snapshotVersion uint8 = 1
+	// snapshotHeaderSize is the size of the snapshot header, preceding its
+	// payload.
+	snapshotHeaderSize = len(snapshotMagic) + 1 + 4
+	// snapshotFixedPayloadSize is the maximum size of the payload fields
+	// preceding the entries.
+	snapshotFixedPayloadSize = 1 + 2*binary.MaxVarintLen64
+	// snapshotMaxEntrySize is the maximum size of an encoded entry.
+	snapshotMaxEntrySize = 8 + 2*binary.MaxVarintLen64
+	// snapshotReadBufferSize is the size of the buffer used to read snapshots.
+	snapshotReadBufferSize = 4_096
+)
+
+var (
+	// ErrInvalidSnapshot is returned when reading a malformed snapshot.
+	ErrInvalidSnapshot = errors.New("invalid snapshot")
+	// ErrUnsupportedSnapshotVersion is returned when reading a snapshot encoded
+	// with an unsupported version.
+	ErrUnsupportedSnapshotVersion = errors.New("unsupported snapshot version")
+)
+
+// WriteTo writes a snapshot of the live entries of the [LRU] to w, which can
+// be restored with [LRU.ReadFrom]. Expired entries are omitted.
+func (m *LRU) WriteTo(w io.Writer) (int64, error) {
+	now := m.clock.Now()
+	table := m.table.Load()
+
+	var (
+		resolution byte
+		count      uint64
+		entries    []byte
+	)
+	if m.resolution == time.Millisecond {
+		resolution = 1
+	}
+	for i := range table.capacity {
+		entry := &table.entries[i]
+		if entry.BlankOrExpired(now, m.interval) {
+			continue
+		}
+		copied := entry.Copyable()
+		if copied.Key == m.zeroKey {
+			// The zero key of the restoring LRU will likely be different
+			copied.Key = 0
+		}
+		entries = binary.LittleEndian.AppendUint64(entries, copied.Key)
+		entries = binary.AppendUvarint(entries, uint64(copied.Data.SampleAge(now, m.interval)))
+		entries = binary.AppendUvarint(entries, uint64(max(0, int32(copied.Data.AccessTime()-copied.Data.SampleTime()))))
+		count++
+	}
+
+	payload := make([]byte, 0, 1+2*binary.MaxVarintLen64+len(entries))
+	payload = append(payload, resolution)
+	payload = binary.AppendVarint(payload, m.clock.clock())
+	payload = binary.AppendUvarint(payload, count)
+	payload = append(payload, entries...)
+
+	buf := make([]byte, 0, snapshotHeaderSize+len(payload))
+	buf = append(buf, snapshotMagic...)
+	buf = append(buf, snapshotVersion)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(payload)))
+	buf = append(buf, payload...)
+
+	n, err := w.Write(buf)
+	return int64(n), err
+}
+
+// ReadFrom restores the entries of a snapshot written by [LRU.WriteTo] from r,
+// replacing the current content of the [LRU]. The time elapsed since the
+// snapshot was taken is accounted for, so that expired entries are dropped. At
+// most [LRU.maxItemCount] of the most recently sampled entries are restored,
+// and snapshots holding more than twice [LRU.capacity] entries are rejected.
+// It reads exactly the bytes of the snapshot, so that several snapshots can be
+// read from the same reader.
+func (m *LRU) ReadFrom(r io.Reader) (int64, error) {
+	var header [snapshotHeaderSize]byte
+	n, err := io.ReadFull(r, header[:])
+	if err != nil {
+		return int64(n), fmt.Errorf("%w: could not read the header: %w", ErrInvalidSnapshot, err)
+	}
+	if string(header[:len(snapshotMagic)]) != snapshotMagic {
+		return int64(n), fmt.Errorf("%w: unexpected magic %q", ErrInvalidSnapshot, header[:len(snapshotMagic)])
+	}
+	if version := header[len(snapshotMagic)]; version != snapshotVersion {
+		return int64(n), fmt.Errorf("%w: %d", ErrUnsupportedSnapshotVersion, version)
+	}
+	length := int64(binary.LittleEndian.Uint32(header[len(snapshotMagic)+1:]))
+	if length > m.maxSnapshotPayloadSize() {
+		return int64(n), fmt.Errorf("%w: payload too large (%d bytes)", ErrInvalidSnapshot, length)
+	}
+
+	// The payload is decoded as it is read, so that nothing is allocated for
+	// the entries of malformed snapshots; reading past the payload is
+	// prevented by limiting the buffered reader to it.
+	payload := &io.LimitedReader{R: r, N: length}
+	br := bufio.NewReaderSize(payload, int(min(length, snapshotReadBufferSize)))
+	table, err := m.decode(br)
+	read := length - payload.N - int64(br.Buffered())
+	n += int(read)
+	if err != nil {
+		return int64(n), err
+	}
+	if read != length {
+		return int64(n), fmt.Errorf("%w: truncated payload", ErrInvalidSnapshot)
+	}
+	m.table.Store(table)
+	return int64(n), nil
+}
+
+// maxSnapshotPayloadSize returns the size of the largest payload accepted by
+// [LRU.ReadFrom], which holds twice [LRU.capacity] entries.
+func (m *LRU) maxSnapshotPayloadSize() int64 {
+	return snapshotFixedPayloadSize + 2*int64(m.capacity)*snapshotMaxEntrySize
+}
+
+// decode decodes the snapshot payload read from r, and returns a new table
+// holding the restored entries.
+func (m *LRU) decode(r *bufio.Reader) (*table, error) {
+	resolution, err := r.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing resolution", ErrInvalidSnapshot)
+	}
+	var snapshotResolution time.Duration
+	switch resolution {
+	case 0:
+		snapshotResolution = time.Second
+	case 1:
+		snapshotResolution = time.Millisecond
+	default:
+		return nil, fmt.Errorf("%w: unexpected resolution %d", ErrInvalidSnapshot, resolution)
+	}
+
+	snapshotTime, err := binary.ReadVarint(r)
+	if err != nil || snapshotTime < 0 {
+		return nil, fmt.Errorf("%w: malformed time", ErrInvalidSnapshot)
+	}
+	count, err := binary.ReadUvarint(r)
+	if err != nil || count > 2*uint64(m.capacity) {
+		return nil, fmt.Errorf("%w: malformed entry count", ErrInvalidSnapshot)
+	}
+
+	// The time elapsed since the snapshot was taken, in our resolution; it is
+	// considered to be 0 if the clock went backwards.
+	now := m.clock.Now()
+	elapsed := min(max(0, m.clock.clock()-convertDuration(snapshotTime, snapshotResolution, m.resolution)), math.MaxInt64/2)
+
+	entries := make([]copiableEntry, 0, min(count, uint64(m.capacity)))
+	var keyBytes [8]byte
+	for range count {
+		if _, err := io.ReadFull(r, keyBytes[:]); err != nil {
+			return nil, fmt.Errorf("%w: truncated entry", ErrInvalidSnapshot)
+		}
+		key := binary.LittleEndian.Uint64(keyBytes[:])
+		sampleAge, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, fmt.Errorf("%w: malformed entry sample age", ErrInvalidSnapshot)
+		}
+		accessDelta, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, fmt.Errorf("%w: malformed entry access delta", ErrInvalidSnapshot)
+		}
+
+		age := convertDuration(int64(min(sampleAge, math.MaxUint32)), snapshotResolution, m.resolution) + elapsed
+		if age >= int64(m.interval) {
+			// The entry has expired since the snapshot was taken
+			continue
+		}
+		if key == 0 {
+			key = m.zeroKey
+		}
+		stime := now - uint32(age)
+		atime := stime + uint32(min(convertDuration(int64(min(accessDelta, math.MaxUint32)), snapshotResolution, m.resolution), age))
+		entries = append(entries, copiableEntry{Key: key, Data: newEntryData(atime, stime)})
+	}
+	if _, err := r.Peek(1); err == nil {
+		return nil, fmt.Errorf("%w: trailing bytes", ErrInvalidSnapshot)
+	}
+
+	// Keep the most recently sampled entries
+	slices.SortFunc(entries, copiableEntry.Compare)
+	entries = entries[:min(len(entries), int(m.maxItemCount))]
+
+	table := newTable(int(m.capacity))
+	for _, entry := range entries {
+		slot, exists := table.FindEntry(entry.Key)
+		if exists {
+			// Duplicate key
+			continue
+		}
+		slot.Key.Store(entry.Key)
+		slot.Data.Store(entry.Data)
+		table.count.Add(1)
+	}
+	return table, nil
+}
+
+// convertDuration converts the given amount of time units, or clock time, from
+// one resolution to another, rounding down.
+func convertDuration(d int64, from time.Duration, to time.Duration) int64 {
+	switch {
+	case from == to:
+		return d
+	case from > to:
+		factor := int64(from / to)
+		if d > math.MaxInt64/factor {
+			return math.MaxInt64
+		}
+		return d * factor
+	default:
+		return d / int64(to/from)
+	}
+}
@@ -0,0 +1,189 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+package timed
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRUSnapshot(t *testing.T) {
+	t.Run("round-trip", func(t *testing.T) {
+		fakeTime := time.Now().Unix()
+		fakeClock := func() int64 { return fakeTime }
+
+		const interval = 30 * time.Second
+		original := NewLRU(interval, fakeClock)
+		require.True(t, original.Hit(1))
+		fakeTime += 20
+		require.True(t, original.Hit(2))
+		require.True(t, original.Hit(0))
+		fakeTime += 5
+
+		var buf bytes.Buffer
+		n, err := original.WriteTo(&buf)
+		require.NoError(t, err)
+		require.EqualValues(t, buf.Len(), n)
+
+		// Restore 10 seconds later: the entry of key 1 has expired since.
+		fakeTime += 10
+		restored := NewLRU(interval, fakeClock)
+		read, err := restored.ReadFrom(&buf)
+		require.NoError(t, err)
+		require.Equal(t, n, read)
+		require.Zero(t, buf.Len())
+		require.Equal(t, 2, restored.Stats().LiveEntries)
+
+		require.True(t, restored.Hit(1))
+		require.False(t, restored.Hit(2))
+		require.False(t, restored.Hit(0))
+
+		// The restored entries expire once their interval has elapsed.
+		fakeTime += 14
+		require.False(t, restored.Hit(2))
+		fakeTime++
+		require.True(t, restored.Hit(2))
+		require.True(t, restored.Hit(0))
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		original := NewLRU(time.Minute, UnixTime)
+
+		var buf bytes.Buffer
+		_, err := original.WriteTo(&buf)
+		require.NoError(t, err)
+
+		restored := NewLRU(time.Minute, UnixTime)
+		require.True(t, restored.Hit(1337))
+		_, err = restored.ReadFrom(&buf)
+		require.NoError(t, err)
+		require.Zero(t, restored.Stats().LiveEntries)
+		require.True(t, restored.Hit(1337))
+	})
+
+	t.Run("resolution", func(t *testing.T) {
+		fakeTime := time.Now().UnixMilli()
+		fakeClock := func() int64 { return fakeTime }
+		original := NewLRU(10*time.Second, fakeClock, WithResolution(time.Millisecond))
+		require.True(t, original.Hit(1))
+		fakeTime += 2_500
+
+		var buf bytes.Buffer
+		_, err := original.WriteTo(&buf)
+		require.NoError(t, err)
+
+		fakeSeconds := fakeTime / 1_000
+		restored := NewLRU(10*time.Second, func() int64 { return fakeSeconds })
+		_, err = restored.ReadFrom(&buf)
+		require.NoError(t, err)
+		require.False(t, restored.Hit(1))
+		fakeSeconds += 10
+		require.True(t, restored.Hit(1))
+	})
+
+	t.Run("max-item-count", func(t *testing.T) {
+		fakeTime := time.Now().Unix()
+		fakeClock := func() int64 { return fakeTime }
+		original := NewLRU(time.Hour, fakeClock)
+		for key := range uint64(20) {
+			require.True(t, original.Hit(key+1))
+			fakeTime++
+		}
+
+		var buf bytes.Buffer
+		_, err := original.WriteTo(&buf)
+		require.NoError(t, err)
+
+		restored := NewLRU(time.Hour, fakeClock, WithMaxItemCount(10))
+		_, err = restored.ReadFrom(&buf)
+		require.NoError(t, err)
+		require.Equal(t, 10, restored.Stats().LiveEntries)
+		// Only the most recently sampled entries are restored.
+		require.False(t, restored.Hit(20))
+		require.False(t, restored.Hit(11))
+		require.True(t, restored.Hit(10))
+	})
+
+	t.Run("concatenated", func(t *testing.T) {
+		fakeTime := time.Now().Unix()
+		fakeClock := func() int64 { return fakeTime }
+		first := NewLRU(time.Minute, fakeClock)
+		second := NewLRU(time.Minute, fakeClock)
+		require.True(t, first.Hit(1))
+		require.True(t, second.Hit(2))
+
+		var buf bytes.Buffer
+		_, err := first.WriteTo(&buf)
+		require.NoError(t, err)
+		_, err = second.WriteTo(&buf)
+		require.NoError(t, err)
+
+		restoredFirst := NewLRU(time.Minute, fakeClock)
+		restoredSecond := NewLRU(time.Minute, fakeClock)
+		_, err = restoredFirst.ReadFrom(&buf)
+		require.NoError(t, err)
+		_, err = restoredSecond.ReadFrom(&buf)
+		require.NoError(t, err)
+		require.False(t, restoredFirst.Hit(1))
+		require.True(t, restoredFirst.Hit(2))
+		require.False(t, restoredSecond.Hit(2))
+		require.True(t, restoredSecond.Hit(1))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		subject := NewLRU(time.Minute, UnixTime)
+		require.True(t, subject.Hit(1))
+		var buf bytes.Buffer
+		_, err := subject.WriteTo(&buf)
+		require.NoError(t, err)
+		valid := buf.Bytes()
+
+		withByte := func(index int, value byte) []byte {
+			data := bytes.Clone(valid)
+			data[index] = value
+			return data
+		}
+
+		for name, tc := range map[string]struct {
+			data []byte
+			err  error
+		}{
+			"empty":             {data: nil, err: ErrInvalidSnapshot},
+			"magic":             {data: withByte(0, 'X'), err: ErrInvalidSnapshot},
+			"version":           {data: withByte(4, snapshotVersion+1), err: ErrUnsupportedSnapshotVersion},
+			"resolution":        {data: withByte(snapshotHeaderSize, 2), err: ErrInvalidSnapshot},
+			"truncated":         {data: valid[:len(valid)-1], err: ErrInvalidSnapshot},
+			"payload-too-short": {data: withByte(5, valid[5]-1), err: ErrInvalidSnapshot},
+			"payload-too-long":  {data: append(withByte(5, valid[5]+1), 0), err: ErrInvalidSnapshot},
+			"payload-too-large": {data: withByte(8, 0xff), err: ErrInvalidSnapshot},
+			// Larger than twice the capacity of the LRU, but not enough to hold
+			// all the entries of the largest supported LRU
+			"payload-over-capacity": {data: withByte(7, 0x08), err: ErrInvalidSnapshot},
+		} {
+			t.Run(name, func(t *testing.T) {
+				restored := NewLRU(time.Minute, UnixTime)
+				require.True(t, restored.Hit(2))
+				_, err := restored.ReadFrom(bytes.NewReader(tc.data))
+				require.ErrorIs(t, err, tc.err)
+				// The content is left untouched on error.
+				require.False(t, restored.Hit(2))
+				require.True(t, restored.Hit(1))
+			})
+		}
+
+		t.Run("payload-size-checked-first", func(t *testing.T) {
+			// The payload of oversized snapshots is never read
+			data := withByte(7, 0x08)
+			restored := NewLRU(time.Minute, UnixTime)
+			n, err := restored.ReadFrom(bytes.NewReader(data))
+			require.ErrorContains(t, err, "payload too large")
+			require.EqualValues(t, snapshotHeaderSize, n)
+		})
+	})
+}
@@ -6,15 +6,14 @@
 package timed
 
 import (
+	"math"
 	"slices"
 	"sync/atomic"
-
-	"github.com/DataDog/appsec-internal-go/apisec/internal/config"
 )
 
 type (
 	// table is a simple open-addressing hash table implementation that uses a
-	// fixed array of items.
+	// fixed-size array of items.
 	table struct {
 		// entries is the set of items contained in the table. The last entry is
 		// reserved for cases where all slots are taken before a rebuild is
@@ -22,7 +21,10 @@ type (
 		// impossibility to find an empty slot, as we always have a slot to return.
 		// We could return a throw-away slot but this would incur a heap allocation,
 		// which we can spare by doing this).
-		entries [capacity + 1]entry
+		entries []entry
+		// capacity is the number of usable slots in entries, which excludes the
+		// last resort entry.
+		capacity uint64
 		// count is the number of items currently stored in the table.
 		count atomic.Int32
 	}
@@ -52,11 +54,19 @@ type (
 	}
 )
 
+// newTable creates a new, empty [table] with the given number of usable slots.
+func newTable(capacity int) *table {
+	return &table{
+		entries:  make([]entry, capacity+1),
+		capacity: uint64(capacity),
+	}
+}
+
 // FindEntry locates the correct entry for use in the table. If an entry already
 // exists for the given key, it is returned with true. If not, the first blank
 // entry is returned with false.
 func (t *table) FindEntry(key uint64) (*entry, bool) {
-	origIdx := key % capacity
+	origIdx := key % t.capacity
 	idx := origIdx
 
 	for {
@@ -66,7 +76,7 @@ func (t *table) FindEntry(key uint64) (*entry, bool) {
 			// claim for this key.
 			return entry, curKey == key
 		}
-		idx = (idx + 1) % capacity
+		idx = (idx + 1) % t.capacity
 		if idx == origIdx {
 			// We are back at the original index, meaning the map is full.
 			break
@@ -74,25 +84,25 @@ func (t *table) FindEntry(key uint64) (*entry, bool) {
 	}
 	// We have gone full circle without finding a blank slot, so we give up and
 	// return our last resort slot that is reserved for this situation.
-	return &t.entries[capacity], true
+	return &t.entries[t.capacity], true
 }
 
 // PrunedCopy creates a copy of this table with expired items removed, retaining
-// up to the [config.MaxItemCount]*2/3 most recent items from the original.
-func (t *table) PrunedCopy(threshold uint32) *table {
+// up to the maxItemCount*2/3 most recent items from the original.
+func (t *table) PrunedCopy(now uint32, interval uint32, maxItemCount int32) *table {
 	// Sort the existing entries (most recent at the top)
-	newEntries := make([]copiableEntry, 0, capacity)
-	for i := range capacity {
-		if t.entries[i].BlankOrExpired(threshold) {
+	newEntries := make([]copiableEntry, 0, t.capacity)
+	for i := range t.capacity {
+		if t.entries[i].BlankOrExpired(now, interval) {
 			continue
 		}
 		newEntries = append(newEntries, t.entries[i].Copyable())
 	}
 	slices.SortFunc(newEntries, copiableEntry.Compare)
 
-	// Insert up to [config.MaxItemCount]*2/3 items into the new table
-	t = new(table)
-	count := min(int32(config.MaxItemCount*2/3), int32(len(newEntries)))
+	// Insert up to maxItemCount*2/3 items into the new table
+	t = newTable(int(t.capacity))
+	count := min(maxItemCount*2/3, int32(len(newEntries)))
 	for _, entry := range newEntries[:count] {
 		slot, _ := t.FindEntry(entry.Key)
 		slot.Key.Store(entry.Key)
@@ -104,8 +114,8 @@ func (t *table) PrunedCopy(threshold uint32) *table {
 }
 
 // BlankOrExpired returns true if the receiver is blank or has expired already.
-func (e *entry) BlankOrExpired(threshold uint32) bool {
-	return e.Key.Load() == 0 || e.Data.Load().SampleTime() < threshold
+func (e *entry) BlankOrExpired(now uint32, interval uint32) bool {
+	return e.Key.Load() == 0 || e.Data.Load().SampleAge(now, interval) > interval
 }
 
 // Copyable returns a [copyableEntry] version of this entry.
@@ -156,6 +166,23 @@ func (d entryData) LastAccessKept() bool {
 	return d.AccessTime() != 0 && d.AccessTime() == d.SampleTime()
 }
 
+// SampleAge returns the time elapsed between the last time this entry was
+// sampled and now. Timestamps are compared using serial number arithmetic, so
+// that they can wrap around. A sample time up to interval in the future (as can
+// be observed when racing with a concurrent update) results in a zero age.
+//
+// Note: an entry that has not been sampled for a multiple of 2^32 time units
+// (about 49.7 days at millisecond resolution) is considered recent for up to
+// two intervals.
+func (d entryData) SampleAge(now uint32, interval uint32) uint32 {
+	age := now - d.SampleTime()
+	if age > math.MaxUint32-interval {
+		// Sampled in our near future
+		return 0
+	}
+	return age
+}
+
 // WithAccessTime returns a new [entryData] by copying the receiver and
 // replacing the access time portion with the specified value.
 func (d entryData) WithAccessTime(atime uint32) entryData {
@@ -168,14 +195,21 @@ func (d entryData) WithAccessTime(atime uint32) entryData {
 func (e copiableEntry) Compare(other copiableEntry) int {
 	tst := e.Data.SampleTime()
 	ost := other.Data.SampleTime()
-	if tst < ost {
+	if timeBefore(tst, ost) {
 		// Receiver was sampled more recently (sorts higher)
 		return 1
 	}
-	if tst > ost {
+	if timeBefore(ost, tst) {
 		// Receiver was sampled less recently (sorts lower)
 		return -1
 	}
 	// Both have the same sample time, so we consider them equal.
 	return 0
 }
+
+// timeBefore returns true if timestamp a is before timestamp b. Timestamps are
+// compared using serial number arithmetic (RFC 1982), so that they can wrap
+// around, as long as they are less than 2^31 time units apart.
+func timeBefore(a uint32, b uint32) bool {
+	return int32(a-b) < 0
+}
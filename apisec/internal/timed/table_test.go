@@ -6,7 +6,9 @@
 package timed
 
 import (
+	"math"
 	"math/rand/v2"
+	"slices"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -34,4 +36,47 @@ func TestEntryData(t *testing.T) {
 			require.Equal(t, stime, subject.SampleTime()) // Unchanged
 		})
 	})
+
+	t.Run("SampleAge", func(t *testing.T) {
+		for _, tc := range []struct {
+			name     string
+			stime    uint32
+			now      uint32
+			expected uint32
+		}{
+			{name: "past", stime: 100, now: 150, expected: 50},
+			{name: "present", stime: 150, now: 150, expected: 0},
+			{name: "near-future", stime: 160, now: 150, expected: 0},
+			{name: "wraparound", stime: math.MaxUint32 - 9, now: 40, expected: 50},
+			{name: "wraparound-near-future", stime: 5, now: math.MaxUint32 - 4, expected: 0},
+			{name: "long-ago", stime: 100, now: 100 + 1<<31, expected: 1 << 31},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				require.Equal(t, tc.expected, newEntryData(tc.now, tc.stime).SampleAge(tc.now, 30))
+			})
+		}
+	})
+}
+
+func TestTimeBefore(t *testing.T) {
+	require.True(t, timeBefore(1, 2))
+	require.False(t, timeBefore(2, 1))
+	require.False(t, timeBefore(2, 2))
+	require.True(t, timeBefore(math.MaxUint32, 0))
+	require.False(t, timeBefore(0, math.MaxUint32))
+}
+
+func TestCopiableEntryCompare(t *testing.T) {
+	entries := []copiableEntry{
+		{Key: 1, Data: newEntryData(0, math.MaxUint32-1)},
+		{Key: 2, Data: newEntryData(0, 5)},
+		{Key: 3, Data: newEntryData(0, math.MaxUint32)},
+		{Key: 4, Data: newEntryData(0, 2)},
+	}
+	slices.SortFunc(entries, copiableEntry.Compare)
+	keys := make([]uint64, len(entries))
+	for i, entry := range entries {
+		keys[i] = entry.Key
+	}
+	require.Equal(t, []uint64{2, 4, 3, 1}, keys)
 }
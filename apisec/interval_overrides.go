@@ -0,0 +1,215 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+package apisec
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// IntervalOverride overrides the sampling interval of the keys matching its
+	// method and route.
+	IntervalOverride struct {
+		// Method is the HTTP method of the matching keys, compared
+		// case-insensitively. It matches any method when empty.
+		Method string
+		// Route is the route of the matching keys. It matches any route starting
+		// with the given prefix when it ends with `*`, and any route when empty.
+		Route string
+		// Interval is the sampling interval of the matching keys. The matching
+		// keys are never sampled when it is negative (see [NeverSample]).
+		Interval time.Duration
+	}
+
+	overrideSampler struct {
+		// defaultSampler samples the keys not matching any override.
+		defaultSampler Sampler
+		// overrides are the overrides, in order of precedence, along with the
+		// sampler of their interval.
+		overrides []override
+		// neverDrops is the number of DROP decisions made for keys matching an
+		// override with a negative interval.
+		neverDrops atomic.Uint64
+	}
+
+	override struct {
+		IntervalOverride
+		// sampler samples the matching keys, or is nil if they are never sampled.
+		sampler Sampler
+	}
+)
+
+// NeverSample is the [IntervalOverride.Interval] of keys that should never be
+// sampled.
+const NeverSample time.Duration = -1
+
+// NewSamplerWithOverrides returns a new [Sampler] with the specified default
+// interval, and the interval of the keys matching the given overrides
+// overridden. The first matching override applies. The keys of all the
+// overrides with the same interval are tracked together, by a sampler with the
+// given options.
+func NewSamplerWithOverrides(interval time.Duration, overrides []IntervalOverride, opts ...SamplerOption) Sampler {
+	return newOverrideSampler(interval, overrides, nil, opts...)
+}
+
+// newOverrideSampler allows creating a new override sampler with a custom
+// clock function, which is useful for testing.
+func newOverrideSampler(interval time.Duration, overrides []IntervalOverride, clock clockFunc, opts ...SamplerOption) Sampler {
+	defaultSampler := newSampler(interval, clock, opts...)
+	if len(overrides) == 0 {
+		return defaultSampler
+	}
+
+	samplers := map[time.Duration]Sampler{interval: defaultSampler}
+	s := &overrideSampler{
+		defaultSampler: defaultSampler,
+		overrides:      make([]override, len(overrides)),
+	}
+	for i, o := range overrides {
+		s.overrides[i].IntervalOverride = o
+		if o.Interval < 0 {
+			continue
+		}
+		sampler, found := samplers[o.Interval]
+		if !found {
+			sampler = newSampler(o.Interval, clock, opts...)
+			samplers[o.Interval] = sampler
+		}
+		s.overrides[i].sampler = sampler
+	}
+	return s
+}
+
+// DecisionFor makes a sampling decision for the provided [SamplingKey], using
+// the interval of the first override it matches, or the default interval.
+func (s *overrideSampler) DecisionFor(key SamplingKey) bool {
+	for i := range s.overrides {
+		o := &s.overrides[i]
+		if !o.Matches(key) {
+			continue
+		}
+		if o.sampler == nil {
+			s.neverDrops.Add(1)
+			return false
+		}
+		return o.sampler.DecisionFor(key)
+	}
+	return s.defaultSampler.DecisionFor(key)
+}
+
+// Stats returns the statistics of this sampler, summed over the samplers of
+// all its intervals.
+func (s *overrideSampler) Stats() SamplerStats {
+	stats := SamplerStats{Drops: s.neverDrops.Load()}
+	for _, sampler := range s.samplers() {
+		if other, ok := StatsOf(sampler); ok {
+			stats.Keeps += other.Keeps
+			stats.Drops += other.Drops
+			stats.OverflowDrops += other.OverflowDrops
+			stats.Rebuilds += other.Rebuilds
+			stats.LiveEntries += other.LiveEntries
+		}
+	}
+	return stats
+}
+
+// Close releases the resources held by the samplers of all the intervals.
+func (s *overrideSampler) Close() error {
+	var errs []error
+	for _, sampler := range s.samplers() {
+		errs = append(errs, CloseSampler(sampler))
+	}
+	return errors.Join(errs...)
+}
+
+// samplers returns the distinct samplers of all the intervals, the default one
+// first, followed by the ones of the overrides in order of precedence.
+func (s *overrideSampler) samplers() []Sampler {
+	samplers := make([]Sampler, 0, len(s.overrides)+1)
+	samplers = append(samplers, s.defaultSampler)
+	for _, o := range s.overrides {
+		if o.sampler != nil && !slices.Contains(samplers, o.sampler) {
+			samplers = append(samplers, o.sampler)
+		}
+	}
+	return samplers
+}
+
+// Matches returns true if the given key matches the method and route of this
+// override.
+func (o IntervalOverride) Matches(key SamplingKey) bool {
+	if o.Method != "" && !strings.EqualFold(o.Method, key.Method) {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(o.Route, "*"); ok {
+		return strings.HasPrefix(key.Route, prefix)
+	}
+	return o.Route == "" || o.Route == key.Route
+}
+
+// ParseIntervalOverrides parses a comma-separated list of interval overrides
+// of the form `[METHOD ]ROUTE=INTERVAL`, such as
+// `POST /login=5s,/health*=never,GET /metrics=3600`. The interval is either a
+// duration, a number of seconds, or `never`. Intervals longer than
+// [math.MaxUint32] units of the resolution of their sampler (see
+// [WithMillisecondResolution]) are rejected.
+func ParseIntervalOverrides(value string) ([]IntervalOverride, error) {
+	var overrides []IntervalOverride
+	for _, elem := range strings.Split(value, ",") {
+		elem = strings.TrimSpace(elem)
+		if elem == "" {
+			continue
+		}
+		matcher, intervalStr, found := strings.Cut(elem, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid interval override %q: expecting [METHOD ]ROUTE=INTERVAL", elem)
+		}
+
+		var o IntervalOverride
+		matcher = strings.TrimSpace(matcher)
+		if method, route, found := strings.Cut(matcher, " "); found {
+			o.Method, o.Route = method, strings.TrimSpace(route)
+		} else {
+			o.Route = matcher
+		}
+
+		interval, err := parseOverrideInterval(strings.TrimSpace(intervalStr))
+		if err != nil {
+			return nil, fmt.Errorf("invalid interval override %q: %w", elem, err)
+		}
+		o.Interval = interval
+		overrides = append(overrides, o)
+	}
+	return overrides, nil
+}
+
+// parseOverrideInterval parses the interval of an interval override.
+func parseOverrideInterval(value string) (time.Duration, error) {
+	if strings.EqualFold(value, "never") {
+		return NeverSample, nil
+	}
+	if seconds, err := strconv.ParseUint(value, 10, 32); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if interval < 0 {
+		return 0, fmt.Errorf("negative interval %s", interval)
+	}
+	if maxInterval := newSamplerConfig(interval, nil).resolution * math.MaxUint32; interval > maxInterval {
+		return 0, fmt.Errorf("interval %s is longer than %s", interval, maxInterval)
+	}
+	return interval, nil
+}
@@ -0,0 +1,124 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+package apisec
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverrideSampler(t *testing.T) {
+	fakeTime := time.Now().Unix()
+	clock := func() int64 { return fakeTime }
+
+	subject := newOverrideSampler(30*time.Second, []IntervalOverride{
+		{Method: http.MethodPost, Route: "/login", Interval: 5 * time.Second},
+		{Route: "/health*", Interval: NeverSample},
+		{Route: "/metrics", Interval: time.Hour},
+		{Route: "/admin", Interval: time.Hour},
+	}, clock)
+
+	var (
+		login    = SamplingKey{Method: http.MethodPost, Route: "/login", StatusCode: http.StatusOK}
+		getLogin = SamplingKey{Method: http.MethodGet, Route: "/login", StatusCode: http.StatusOK}
+		health   = SamplingKey{Method: http.MethodGet, Route: "/healthz", StatusCode: http.StatusOK}
+		metrics  = SamplingKey{Method: http.MethodGet, Route: "/metrics", StatusCode: http.StatusOK}
+		admin    = SamplingKey{Method: http.MethodGet, Route: "/admin", StatusCode: http.StatusOK}
+	)
+
+	for _, key := range []SamplingKey{login, getLogin, metrics, admin} {
+		assert.True(t, subject.DecisionFor(key), "%+v", key)
+		assert.False(t, subject.DecisionFor(key), "%+v", key)
+	}
+	assert.False(t, subject.DecisionFor(health))
+
+	fakeTime += 5
+	assert.True(t, subject.DecisionFor(login))
+	assert.False(t, subject.DecisionFor(getLogin))
+
+	fakeTime += 25
+	assert.True(t, subject.DecisionFor(getLogin))
+	assert.False(t, subject.DecisionFor(metrics))
+	assert.False(t, subject.DecisionFor(health))
+
+	fakeTime += 3600
+	assert.True(t, subject.DecisionFor(metrics))
+	assert.True(t, subject.DecisionFor(admin))
+	assert.False(t, subject.DecisionFor(health))
+
+	stats, ok := StatsOf(subject)
+	require.True(t, ok)
+	assert.Equal(t, SamplerStats{Keeps: 8, Drops: 9, LiveEntries: 4}, stats)
+
+	// Overrides with the same interval share the same sampler
+	overrides := subject.(*overrideSampler).overrides
+	assert.Same(t, overrides[2].sampler, overrides[3].sampler)
+
+	t.Run("no-overrides", func(t *testing.T) {
+		assert.IsType(t, &timedSetSampler{}, NewSamplerWithOverrides(time.Second, nil))
+	})
+}
+
+func TestIntervalOverrideMatches(t *testing.T) {
+	key := SamplingKey{Method: http.MethodGet, Route: "/users/{id}", StatusCode: http.StatusOK}
+
+	for _, tc := range []struct {
+		override IntervalOverride
+		matches  bool
+	}{
+		{override: IntervalOverride{}, matches: true},
+		{override: IntervalOverride{Method: "get"}, matches: true},
+		{override: IntervalOverride{Method: http.MethodPost}, matches: false},
+		{override: IntervalOverride{Route: "/users/{id}"}, matches: true},
+		{override: IntervalOverride{Route: "/users"}, matches: false},
+		{override: IntervalOverride{Route: "/users/*"}, matches: true},
+		{override: IntervalOverride{Route: "*"}, matches: true},
+		{override: IntervalOverride{Method: http.MethodGet, Route: "/admin*"}, matches: false},
+	} {
+		assert.Equal(t, tc.matches, tc.override.Matches(key), "%+v", tc.override)
+	}
+}
+
+func TestParseIntervalOverrides(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		value    string
+		expected []IntervalOverride
+		err      bool
+	}{
+		{name: "empty"},
+		{
+			name:  "valid",
+			value: "POST /login=5s, /health*=never ,GET /metrics=3600,/slow=1m30s,/fast=250ms",
+			expected: []IntervalOverride{
+				{Method: http.MethodPost, Route: "/login", Interval: 5 * time.Second},
+				{Route: "/health*", Interval: NeverSample},
+				{Method: http.MethodGet, Route: "/metrics", Interval: time.Hour},
+				{Route: "/slow", Interval: 90 * time.Second},
+				{Route: "/fast", Interval: 250 * time.Millisecond},
+			},
+		},
+		{name: "missing-interval", value: "/login", err: true},
+		{name: "invalid-interval", value: "/login=often", err: true},
+		{name: "negative-interval", value: "/login=-5s", err: true},
+		{name: "too-long-interval", value: "/login=2000000h", err: true},
+		{name: "too-long-millisecond-interval", value: "/login=1500h500ms", err: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			overrides, err := ParseIntervalOverrides(tc.value)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, overrides)
+		})
+	}
+}
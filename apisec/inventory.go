@@ -0,0 +1,346 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+package apisec
+
+import (
+	"cmp"
+	"encoding/json"
+	"io"
+	"math/bits"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// Inventory is a bounded inventory of the endpoints observed by the
+	// service, to be reconciled with its documented API. It is safe for
+	// concurrent use.
+	Inventory struct {
+		// mu protects endpoints; the records themselves are updated atomically.
+		mu        sync.RWMutex
+		endpoints map[endpointID]*endpointRecord
+		// maxEndpoints is the maximum number of endpoints recorded.
+		maxEndpoints int
+		// evicted is the number of endpoints evicted because the inventory was
+		// full.
+		evicted atomic.Uint64
+		// now returns the current time.
+		now func() time.Time
+	}
+
+	// Endpoint is an endpoint observed by the service, as exported by
+	// [Inventory.Endpoints].
+	Endpoint struct {
+		// Method is the HTTP method of the endpoint.
+		Method string `json:"method"`
+		// Route is the route of the endpoint.
+		Route string `json:"route"`
+		// StatusCodes are the response status codes observed for the endpoint,
+		// in increasing order.
+		StatusCodes []int `json:"status_codes"`
+		// Hits is the number of requests observed for the endpoint.
+		Hits uint64 `json:"hits"`
+		// FirstSeen is the time the endpoint was first observed.
+		FirstSeen time.Time `json:"first_seen"`
+		// LastSeen is the time the endpoint was last observed.
+		LastSeen time.Time `json:"last_seen"`
+	}
+
+	endpointID struct {
+		method string
+		route  string
+	}
+
+	endpointRecord struct {
+		firstSeen int64 // Unix time in nanoseconds
+		lastSeen  atomic.Int64
+		hits      atomic.Uint64
+		// statusCodes is a bit set of the observed status codes, the bit i
+		// standing for the status code minStatusCode+i.
+		statusCodes [statusCodeWords]atomic.Uint64
+	}
+)
+
+const (
+	// DefaultInventorySize is the default maximum number of endpoints recorded
+	// by an [Inventory].
+	DefaultInventorySize = 1_024
+
+	// minStatusCode is the smallest status code recorded by [Inventory].
+	minStatusCode = 100
+	// statusCodeWords is the number of words of the status code bit sets, so
+	// that status codes in [100, 611] are recorded.
+	statusCodeWords = 8
+	// inventoryEvictionSamples is the number of endpoints the evicted one is
+	// chosen among when the inventory is full, so that fewer endpoints than
+	// that hit more often than all the others are never evicted.
+	inventoryEvictionSamples = 16
+)
+
+// NewInventory returns a new, empty [Inventory] recording at most the given
+// number of endpoints, past which the least hit endpoint, or the least recently
+// seen one among them, out of a random sample of the endpoints, is evicted to
+// record a new one. This way, endpoints only hit a few times, such as the ones
+// probed by scanners, are evicted first. If the maximum is not strictly
+// positive, [DefaultInventorySize] is used instead. Routes should be normalized
+// (see [NormalizeRoute]) to keep the number of endpoints low when the framework
+// does not provide them.
+func NewInventory(maxEndpoints int) *Inventory {
+	return newInventory(maxEndpoints, time.Now)
+}
+
+// newInventory allows creating a new inventory with a custom clock function,
+// which is useful for testing.
+func newInventory(maxEndpoints int, now func() time.Time) *Inventory {
+	if maxEndpoints <= 0 {
+		maxEndpoints = DefaultInventorySize
+	}
+	return &Inventory{
+		endpoints:    make(map[endpointID]*endpointRecord),
+		maxEndpoints: maxEndpoints,
+		now:          now,
+	}
+}
+
+// Record records a request to the endpoint identified by the method and route
+// of the given key, along with its response status code.
+func (inv *Inventory) Record(key SamplingKey) {
+	id := endpointID{method: key.Method, route: key.Route}
+	now := inv.now().UnixNano()
+
+	inv.mu.RLock()
+	record, found := inv.endpoints[id]
+	inv.mu.RUnlock()
+
+	if !found {
+		// The record is fully initialized before being published, so that
+		// Endpoints() never exports a record without any hit.
+		newRecord := &endpointRecord{firstSeen: now}
+		newRecord.record(now, key.StatusCode)
+
+		inv.mu.Lock()
+		record, found = inv.endpoints[id]
+		if !found {
+			if len(inv.endpoints) >= inv.maxEndpoints {
+				inv.evict()
+			}
+			inv.endpoints[id] = newRecord
+			inv.mu.Unlock()
+			return
+		}
+		inv.mu.Unlock()
+	}
+
+	record.record(now, key.StatusCode)
+}
+
+// evict removes the least hit endpoint, or the least recently seen one among
+// them, out of a sample of the endpoints, so that the cost of recording new
+// endpoints does not grow with the size of the inventory. Maps are iterated
+// from a random position, so the sample changes on every call. It must be
+// called with the write lock held.
+func (inv *Inventory) evict() {
+	var (
+		evictedID     endpointID
+		evictedRecord *endpointRecord
+		sampled       int
+	)
+	for id, record := range inv.endpoints {
+		if evictedRecord == nil || cmp.Or(
+			cmp.Compare(record.hits.Load(), evictedRecord.hits.Load()),
+			cmp.Compare(record.lastSeen.Load(), evictedRecord.lastSeen.Load()),
+		) < 0 {
+			evictedID, evictedRecord = id, record
+		}
+		if sampled++; sampled >= inventoryEvictionSamples {
+			break
+		}
+	}
+	if evictedRecord != nil {
+		delete(inv.endpoints, evictedID)
+		inv.evicted.Add(1)
+	}
+}
+
+// record records a request seen at the given time, with the given response
+// status code.
+func (r *endpointRecord) record(now int64, statusCode int) {
+	r.hits.Add(1)
+	for {
+		lastSeen := r.lastSeen.Load()
+		if lastSeen >= now || r.lastSeen.CompareAndSwap(lastSeen, now) {
+			break
+		}
+	}
+	if offset := statusCode - minStatusCode; offset >= 0 && offset < statusCodeWords*64 {
+		word, bit := &r.statusCodes[offset/64], uint64(1)<<(offset%64)
+		if word.Load()&bit == 0 {
+			word.Or(bit)
+		}
+	}
+}
+
+// Evicted returns the number of endpoints evicted because the inventory was
+// full.
+func (inv *Inventory) Evicted() uint64 {
+	return inv.evicted.Load()
+}
+
+// Endpoints returns the endpoints recorded so far, sorted by route and method.
+func (inv *Inventory) Endpoints() []Endpoint {
+	inv.mu.RLock()
+	endpoints := make([]Endpoint, 0, len(inv.endpoints))
+	for id, record := range inv.endpoints {
+		endpoints = append(endpoints, Endpoint{
+			Method:      id.method,
+			Route:       id.route,
+			StatusCodes: record.StatusCodes(),
+			Hits:        record.hits.Load(),
+			FirstSeen:   time.Unix(0, record.firstSeen).UTC(),
+			LastSeen:    time.Unix(0, record.lastSeen.Load()).UTC(),
+		})
+	}
+	inv.mu.RUnlock()
+
+	slices.SortFunc(endpoints, func(a, b Endpoint) int {
+		return cmp.Or(strings.Compare(a.Route, b.Route), strings.Compare(a.Method, b.Method))
+	})
+	return endpoints
+}
+
+// StatusCodes returns the status codes recorded in this record, in increasing
+// order.
+func (r *endpointRecord) StatusCodes() []int {
+	codes := []int{}
+	for i := range r.statusCodes {
+		for word := r.statusCodes[i].Load(); word != 0; word &= word - 1 {
+			codes = append(codes, minStatusCode+i*64+bits.TrailingZeros64(word))
+		}
+	}
+	return codes
+}
+
+// WriteJSON writes the endpoints recorded so far to w, as a JSON object with
+// an `endpoints` array (see [Endpoint]), and the number of `evicted` endpoints.
+func (inv *Inventory) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(struct {
+		Endpoints []Endpoint `json:"endpoints"`
+		Evicted   uint64     `json:"evicted"`
+	}{
+		Endpoints: inv.Endpoints(),
+		Evicted:   inv.Evicted(),
+	})
+}
+
+type (
+	openAPIDocument struct {
+		OpenAPI string                                 `json:"openapi"`
+		Info    openAPIInfo                            `json:"info"`
+		Paths   map[string]map[string]openAPIOperation `json:"paths"`
+	}
+
+	openAPIInfo struct {
+		Title   string `json:"title"`
+		Version string `json:"version"`
+	}
+
+	openAPIOperation struct {
+		Parameters []openAPIParameter         `json:"parameters,omitempty"`
+		Responses  map[string]openAPIResponse `json:"responses"`
+	}
+
+	openAPIParameter struct {
+		Name     string        `json:"name"`
+		In       string        `json:"in"`
+		Required bool          `json:"required"`
+		Schema   openAPISchema `json:"schema"`
+	}
+
+	openAPISchema struct {
+		Type string `json:"type"`
+	}
+
+	openAPIResponse struct {
+		Description string `json:"description"`
+	}
+)
+
+// WriteOpenAPI writes the endpoints recorded so far to w, as the path stubs of
+// an OpenAPI 3.0 JSON document with the given title. The `{name}` segments of
+// routes are declared as path parameters, and the observed status codes as
+// responses. Endpoints whose method is not supported by OpenAPI are omitted.
+func (inv *Inventory) WriteOpenAPI(w io.Writer, title string) error {
+	doc := openAPIDocument{
+		OpenAPI: "3.0.3",
+		Info:    openAPIInfo{Title: title, Version: "observed"},
+		Paths:   make(map[string]map[string]openAPIOperation),
+	}
+	for _, endpoint := range inv.Endpoints() {
+		method := strings.ToLower(endpoint.Method)
+		switch method {
+		case "get", "put", "post", "delete", "options", "head", "patch", "trace":
+		default:
+			continue
+		}
+
+		path, params := openAPIPath(endpoint.Route)
+		operation := openAPIOperation{
+			Parameters: params,
+			Responses:  make(map[string]openAPIResponse, len(endpoint.StatusCodes)),
+		}
+		for _, code := range endpoint.StatusCodes {
+			operation.Responses[strconv.Itoa(code)] = openAPIResponse{Description: "Observed response"}
+		}
+		if len(operation.Responses) == 0 {
+			operation.Responses["default"] = openAPIResponse{Description: "Observed response"}
+		}
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]openAPIOperation)
+		}
+		doc.Paths[path][method] = operation
+	}
+	return json.NewEncoder(w).Encode(doc)
+}
+
+// openAPIPath returns the OpenAPI path template of the given route, along with
+// its path parameters. Parameters appearing several times in the route, such
+// as the placeholders of [NormalizeRoute], are renamed with an increasing
+// suffix, as OpenAPI requires their names to be unique.
+func openAPIPath(route string) (string, []openAPIParameter) {
+	segments := strings.Split(route, "/")
+	var (
+		params []openAPIParameter
+		seen   map[string]int
+	)
+	for i, segment := range segments {
+		name, ok := strings.CutPrefix(segment, "{")
+		if !ok {
+			continue
+		}
+		if name, ok = strings.CutSuffix(name, "}"); !ok || name == "" {
+			continue
+		}
+		if seen == nil {
+			seen = make(map[string]int)
+		}
+		if seen[name]++; seen[name] > 1 {
+			name += strconv.Itoa(seen[name])
+			segments[i] = "{" + name + "}"
+		}
+		params = append(params, openAPIParameter{
+			Name:     name,
+			In:       "path",
+			Required: true,
+			Schema:   openAPISchema{Type: "string"},
+		})
+	}
+	return strings.Join(segments, "/"), params
+}
@@ -0,0 +1,267 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+package apisec
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInventory(t *testing.T) {
+	start := time.Date(2026, time.October, 1, 12, 0, 0, 0, time.UTC)
+	newTestInventory := func(maxEndpoints int) (*Inventory, *time.Time) {
+		now := start
+		return newInventory(maxEndpoints, func() time.Time { return now }), &now
+	}
+
+	t.Run("Endpoints", func(t *testing.T) {
+		subject, now := newTestInventory(10)
+		subject.Record(SamplingKey{Method: http.MethodGet, Route: "/users/{id}", StatusCode: http.StatusOK})
+		*now = now.Add(time.Minute)
+		subject.Record(SamplingKey{Method: http.MethodGet, Route: "/users/{id}", StatusCode: http.StatusNotFound})
+		subject.Record(SamplingKey{Method: http.MethodPost, Route: "/users", StatusCode: http.StatusCreated})
+		subject.Record(SamplingKey{Method: http.MethodGet, Route: "/users", StatusCode: http.StatusOK})
+		*now = now.Add(time.Minute)
+		subject.Record(SamplingKey{Method: http.MethodGet, Route: "/users/{id}", StatusCode: http.StatusOK})
+		subject.Record(SamplingKey{Method: http.MethodGet, Route: "/legacy", StatusCode: 999})
+
+		require.Equal(t, []Endpoint{
+			{
+				Method:      http.MethodGet,
+				Route:       "/legacy",
+				StatusCodes: []int{},
+				Hits:        1,
+				FirstSeen:   start.Add(2 * time.Minute),
+				LastSeen:    start.Add(2 * time.Minute),
+			},
+			{
+				Method:      http.MethodGet,
+				Route:       "/users",
+				StatusCodes: []int{http.StatusOK},
+				Hits:        1,
+				FirstSeen:   start.Add(time.Minute),
+				LastSeen:    start.Add(time.Minute),
+			},
+			{
+				Method:      http.MethodPost,
+				Route:       "/users",
+				StatusCodes: []int{http.StatusCreated},
+				Hits:        1,
+				FirstSeen:   start.Add(time.Minute),
+				LastSeen:    start.Add(time.Minute),
+			},
+			{
+				Method:      http.MethodGet,
+				Route:       "/users/{id}",
+				StatusCodes: []int{http.StatusOK, http.StatusNotFound},
+				Hits:        3,
+				FirstSeen:   start,
+				LastSeen:    start.Add(2 * time.Minute),
+			},
+		}, subject.Endpoints())
+	})
+
+	t.Run("bounded", func(t *testing.T) {
+		subject, now := newTestInventory(3)
+		routes := func() []string {
+			var routes []string
+			for _, endpoint := range subject.Endpoints() {
+				routes = append(routes, endpoint.Route)
+			}
+			return routes
+		}
+
+		subject.Record(SamplingKey{Method: http.MethodGet, Route: "/a", StatusCode: http.StatusOK})
+		subject.Record(SamplingKey{Method: http.MethodGet, Route: "/a", StatusCode: http.StatusOK})
+		*now = now.Add(time.Second)
+		subject.Record(SamplingKey{Method: http.MethodGet, Route: "/b", StatusCode: http.StatusOK})
+		*now = now.Add(time.Second)
+		subject.Record(SamplingKey{Method: http.MethodGet, Route: "/c", StatusCode: http.StatusOK})
+
+		// The least hit endpoints are evicted first, the least recently seen one
+		// first among them
+		*now = now.Add(time.Second)
+		subject.Record(SamplingKey{Method: http.MethodGet, Route: "/.env", StatusCode: http.StatusNotFound})
+		require.Equal(t, []string{"/.env", "/a", "/c"}, routes())
+		*now = now.Add(time.Second)
+		subject.Record(SamplingKey{Method: http.MethodGet, Route: "/wp-admin", StatusCode: http.StatusNotFound})
+		require.Equal(t, []string{"/.env", "/a", "/wp-admin"}, routes())
+		*now = now.Add(time.Second)
+		subject.Record(SamplingKey{Method: http.MethodGet, Route: "/.git", StatusCode: http.StatusNotFound})
+		require.Equal(t, []string{"/.git", "/a", "/wp-admin"}, routes())
+		require.EqualValues(t, 3, subject.Evicted())
+
+		require.Equal(t, DefaultInventorySize, NewInventory(0).maxEndpoints)
+	})
+
+	t.Run("scanned", func(t *testing.T) {
+		subject, _ := newTestInventory(4 * inventoryEvictionSamples)
+		popular := make([]SamplingKey, inventoryEvictionSamples-1)
+		for i := range popular {
+			popular[i] = SamplingKey{Method: http.MethodGet, Route: fmt.Sprintf("/api/%d", i), StatusCode: http.StatusOK}
+			subject.Record(popular[i])
+			subject.Record(popular[i])
+		}
+		for i := range 10_000 {
+			subject.Record(SamplingKey{Method: http.MethodGet, Route: fmt.Sprintf("/scan/%d", i), StatusCode: http.StatusNotFound})
+		}
+
+		// The endpoints hit more than once are never evicted by the scan
+		for _, endpoint := range subject.Endpoints() {
+			if endpoint.Hits > 1 {
+				popular = slices.DeleteFunc(popular, func(key SamplingKey) bool { return key.Route == endpoint.Route })
+			}
+		}
+		require.Empty(t, popular)
+		require.EqualValues(t, 10_000-3*inventoryEvictionSamples-1, subject.Evicted())
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		subject := NewInventory(10)
+		var wg sync.WaitGroup
+		for i := range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range 1_000 {
+					subject.Record(SamplingKey{Method: http.MethodGet, Route: "/", StatusCode: 200 + i})
+				}
+			}()
+		}
+		wg.Wait()
+
+		endpoints := subject.Endpoints()
+		require.Len(t, endpoints, 1)
+		assert.EqualValues(t, 8_000, endpoints[0].Hits)
+		assert.Equal(t, []int{200, 201, 202, 203, 204, 205, 206, 207}, endpoints[0].StatusCodes)
+	})
+
+	t.Run("concurrent-export", func(t *testing.T) {
+		subject := NewInventory(10)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := range 1_000 {
+				subject.Record(SamplingKey{Method: http.MethodGet, Route: fmt.Sprintf("/%d", i), StatusCode: http.StatusOK})
+			}
+		}()
+
+		// Endpoints are only exported once their first hit is recorded
+		for exporting := true; exporting; {
+			select {
+			case <-done:
+				exporting = false
+			default:
+			}
+			for _, endpoint := range subject.Endpoints() {
+				require.EqualValues(t, 1, endpoint.Hits)
+				require.Equal(t, endpoint.FirstSeen, endpoint.LastSeen)
+				require.Equal(t, []int{http.StatusOK}, endpoint.StatusCodes)
+			}
+		}
+		require.EqualValues(t, 990, subject.Evicted())
+	})
+
+	t.Run("WriteJSON", func(t *testing.T) {
+		subject, _ := newTestInventory(1)
+		subject.Record(SamplingKey{Method: http.MethodGet, Route: "/users/{id}", StatusCode: http.StatusOK})
+		subject.Record(SamplingKey{Method: http.MethodGet, Route: "/users", StatusCode: http.StatusOK})
+
+		var buf bytes.Buffer
+		require.NoError(t, subject.WriteJSON(&buf))
+		assert.JSONEq(t, `{
+			"endpoints": [{
+				"method": "GET",
+				"route": "/users",
+				"status_codes": [200],
+				"hits": 1,
+				"first_seen": "2026-10-01T12:00:00Z",
+				"last_seen": "2026-10-01T12:00:00Z"
+			}],
+			"evicted": 1
+		}`, buf.String())
+	})
+
+	t.Run("WriteOpenAPI", func(t *testing.T) {
+		subject, _ := newTestInventory(10)
+		subject.Record(SamplingKey{Method: http.MethodGet, Route: "/users/{id}", StatusCode: http.StatusOK})
+		subject.Record(SamplingKey{Method: http.MethodGet, Route: "/users/{id}", StatusCode: http.StatusNotFound})
+		subject.Record(SamplingKey{Method: http.MethodDelete, Route: "/users/{id}", StatusCode: http.StatusNoContent})
+		subject.Record(SamplingKey{Method: http.MethodGet, Route: NormalizeRoute("/orders/12/items/34"), StatusCode: http.StatusOK})
+		subject.Record(SamplingKey{Method: http.MethodPost, Route: "/legacy", StatusCode: 999})
+		subject.Record(SamplingKey{Method: http.MethodConnect, Route: "/tunnel", StatusCode: http.StatusOK})
+
+		var buf bytes.Buffer
+		require.NoError(t, subject.WriteOpenAPI(&buf, "My Service"))
+		assert.JSONEq(t, `{
+			"openapi": "3.0.3",
+			"info": {"title": "My Service", "version": "observed"},
+			"paths": {
+				"/legacy": {
+					"post": {
+						"responses": {"default": {"description": "Observed response"}}
+					}
+				},
+				"/orders/{int}/items/{int2}": {
+					"get": {
+						"parameters": [
+							{"name": "int", "in": "path", "required": true, "schema": {"type": "string"}},
+							{"name": "int2", "in": "path", "required": true, "schema": {"type": "string"}}
+						],
+						"responses": {"200": {"description": "Observed response"}}
+					}
+				},
+				"/users/{id}": {
+					"delete": {
+						"parameters": [{"name": "id", "in": "path", "required": true, "schema": {"type": "string"}}],
+						"responses": {"204": {"description": "Observed response"}}
+					},
+					"get": {
+						"parameters": [{"name": "id", "in": "path", "required": true, "schema": {"type": "string"}}],
+						"responses": {
+							"200": {"description": "Observed response"},
+							"404": {"description": "Observed response"}
+						}
+					}
+				}
+			}
+		}`, buf.String())
+	})
+}
+
+func BenchmarkInventory(b *testing.B) {
+	keys := make([]SamplingKey, 4*DefaultInventorySize)
+	for i := range keys {
+		keys[i] = SamplingKey{Method: http.MethodGet, Route: fmt.Sprintf("/%d", i), StatusCode: http.StatusOK}
+	}
+
+	for name, keySpace := range map[string][]SamplingKey{
+		"known": keys[:DefaultInventorySize],
+		"full":  keys,
+	} {
+		b.Run(name, func(b *testing.B) {
+			subject := NewInventory(DefaultInventorySize)
+			for _, key := range keys[:DefaultInventorySize] {
+				subject.Record(key)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for i := 0; pb.Next(); i++ {
+					subject.Record(keySpace[i%len(keySpace)])
+				}
+			})
+		})
+	}
+}
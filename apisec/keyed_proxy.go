@@ -0,0 +1,96 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+package apisec
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/DataDog/appsec-internal-go/apisec/internal/timed"
+	"github.com/DataDog/appsec-internal-go/limiter"
+)
+
+// keyedProxySampler samples each key at most once per interval, like
+// [timedSetSampler], while enforcing a global rate like [proxySampler].
+type keyedProxySampler struct {
+	lru     *timed.LRU
+	limiter limiter.Limiter
+	keeps   atomic.Uint64
+	drops   atomic.Uint64
+	// wastedRateDrops is the number of DROP decisions made after consuming a
+	// rate token.
+	wastedRateDrops atomic.Uint64
+}
+
+// NewKeyedProxySampler creates a new sampler suitable for proxy environments,
+// keeping at most rate samples per interval overall, like [NewProxySampler],
+// and each key at most once per keyInterval, like [NewSamplerWithInterval]. The
+// rate is thus spread across keys, rather than being consumed by the most
+// frequent ones. Keys are tracked by a sampler with the given options.
+func NewKeyedProxySampler(rate int, interval time.Duration, keyInterval time.Duration, opts ...SamplerOption) Sampler {
+	if rate <= 0 {
+		return &nullSampler{}
+	}
+	r := int64(rate)
+	l := limiter.NewTokenTickerWithInterval(r, r, interval)
+	l.Start()
+	return newKeyedProxySampler(l, keyInterval, nil, opts...)
+}
+
+// newKeyedProxySampler allows creating a new keyed proxy sampler with a custom
+// limiter and clock function, which is useful for testing.
+func newKeyedProxySampler(l limiter.Limiter, keyInterval time.Duration, clock clockFunc, opts ...SamplerOption) *keyedProxySampler {
+	return &keyedProxySampler{
+		lru:     (*timed.LRU)(newSampler(keyInterval, clock, opts...).(*timedSetSampler)),
+		limiter: l,
+	}
+}
+
+// DecisionFor makes a sampling decision for the provided [SamplingKey]. Keys
+// sampled within the interval are dropped without consuming the rate, and keys
+// dropped because the rate is exhausted may be kept by subsequent calls.
+//
+// The rate is consumed before the key is recorded, so that keys dropped
+// because the rate is exhausted are not recorded, and can be kept as soon as
+// the rate allows. When the key then cannot be recorded, because another
+// goroutine concurrently sampled it or the sampler is full, the request is
+// dropped and the rate it consumed is lost. This is reported as
+// [SamplerStats.WastedRateDrops].
+func (s *keyedProxySampler) DecisionFor(key SamplingKey) bool {
+	keyHash := key.Hash()
+	if s.lru.Sampled(keyHash) || !s.limiter.Allow() {
+		s.drops.Add(1)
+		return false
+	}
+	if !s.lru.Hit(keyHash) {
+		// Another goroutine has concurrently sampled this key, or the key cannot
+		// be tracked...
+		s.drops.Add(1)
+		s.wastedRateDrops.Add(1)
+		return false
+	}
+	s.keeps.Add(1)
+	return true
+}
+
+// Close stops the rate limiter of this sampler.
+func (s *keyedProxySampler) Close() error {
+	stopLimiter(s.limiter)
+	return nil
+}
+
+// Stats returns the statistics of this sampler.
+func (s *keyedProxySampler) Stats() SamplerStats {
+	stats := s.lru.Stats()
+	return SamplerStats{
+		Keeps:           s.keeps.Load(),
+		Drops:           s.drops.Load(),
+		OverflowDrops:   stats.OverflowDrops,
+		WastedRateDrops: s.wastedRateDrops.Load(),
+		Rebuilds:        stats.Rebuilds,
+		LiveEntries:     stats.LiveEntries,
+	}
+}
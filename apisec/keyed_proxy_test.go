@@ -0,0 +1,92 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+package apisec
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLimiter is a limiter allowing a fixed number of tokens, until refilled.
+type fakeLimiter struct {
+	tokens int
+	// onAllow is called, if set, every time a token is taken.
+	onAllow func()
+}
+
+func (l *fakeLimiter) Allow() bool {
+	if l.tokens <= 0 {
+		return false
+	}
+	l.tokens--
+	if l.onAllow != nil {
+		l.onAllow()
+	}
+	return true
+}
+
+func TestKeyedProxySampler(t *testing.T) {
+	var (
+		hot  = SamplingKey{Method: http.MethodGet, Route: "/hot", StatusCode: http.StatusOK}
+		cold = SamplingKey{Method: http.MethodGet, Route: "/cold", StatusCode: http.StatusOK}
+	)
+
+	t.Run("rate", func(t *testing.T) {
+		require.IsType(t, &nullSampler{}, NewKeyedProxySampler(0, time.Minute, 30*time.Second))
+	})
+
+	t.Run("fairness", func(t *testing.T) {
+		fakeTime := time.Now().Unix()
+		clock := func() int64 { return fakeTime }
+		limiter := &fakeLimiter{tokens: 2}
+		subject := newKeyedProxySampler(limiter, 30*time.Second, clock)
+
+		// The hot key is sampled once, and its subsequent requests do not consume
+		// the rate, leaving room for the cold key.
+		require.True(t, subject.DecisionFor(hot))
+		for range 100 {
+			require.False(t, subject.DecisionFor(hot))
+		}
+		require.Equal(t, 1, limiter.tokens)
+		require.True(t, subject.DecisionFor(cold))
+		require.Zero(t, limiter.tokens)
+
+		// Once the interval has elapsed, keys are only sampled if the rate allows
+		fakeTime += 30
+		require.False(t, subject.DecisionFor(hot))
+		limiter.tokens = 1
+		require.True(t, subject.DecisionFor(hot))
+		require.False(t, subject.DecisionFor(cold))
+
+		stats, ok := StatsOf(subject)
+		require.True(t, ok)
+		require.Equal(t, SamplerStats{Keeps: 3, Drops: 102, LiveEntries: 2}, stats)
+	})
+
+	t.Run("wasted-rate", func(t *testing.T) {
+		fakeTime := time.Now().Unix()
+		clock := func() int64 { return fakeTime }
+		limiter := &fakeLimiter{tokens: 2}
+		subject := newKeyedProxySampler(limiter, 30*time.Second, clock)
+
+		// Another goroutine samples the key while a token is being taken: the
+		// request is dropped and the token is lost
+		limiter.onAllow = func() { subject.lru.Hit(hot.Hash()) }
+		require.False(t, subject.DecisionFor(hot))
+		require.Equal(t, 1, limiter.tokens)
+
+		limiter.onAllow = nil
+		require.True(t, subject.DecisionFor(cold))
+		require.Zero(t, limiter.tokens)
+
+		stats, ok := StatsOf(subject)
+		require.True(t, ok)
+		require.Equal(t, SamplerStats{Keeps: 1, Drops: 1, WastedRateDrops: 1, LiveEntries: 2}, stats)
+	})
+}
@@ -0,0 +1,92 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+package apisec
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// replicaSampler only samples the keys owned by its replica, delegating the
+// decision to the wrapped sampler, and drops all others.
+type replicaSampler struct {
+	sampler      Sampler
+	replicaID    int
+	replicaCount int
+	// notOwnedDrops is the number of DROP decisions made for keys owned by
+	// other replicas.
+	notOwnedDrops atomic.Uint64
+}
+
+// replicaHashSeed is the seed of the key hashes used to assign keys to
+// replicas, so that the assignment is not correlated with the slots keys are
+// stored in by samplers. It must be the same for all replicas, and never
+// change.
+const replicaHashSeed = 0x5265706c69636173 // "Replicas"
+
+// NewReplicaSampler returns a [Sampler] for the replica with the given ID among
+// replicaCount replicas, that only samples the keys owned by this replica (see
+// [ReplicaOf]) using the given sampler, and drops all others. When all the
+// replicas are configured with the same count, each key is sampled by a single
+// replica, without any communication between them. It panics if the replica ID
+// is not in [0, replicaCount).
+func NewReplicaSampler(replicaID, replicaCount int, sampler Sampler) Sampler {
+	if replicaID < 0 || replicaID >= replicaCount {
+		panic(fmt.Errorf("NewReplicaSampler: replica ID must be in [0, %d), but was %d", replicaCount, replicaID))
+	}
+	if replicaCount == 1 {
+		return sampler
+	}
+	return &replicaSampler{
+		sampler:      sampler,
+		replicaID:    replicaID,
+		replicaCount: replicaCount,
+	}
+}
+
+// ReplicaOf returns the ID of the replica owning the given key among
+// replicaCount replicas, in [0, replicaCount). Keys are assigned using
+// consistent hashing, so that only 1/replicaCount of the keys change owner when
+// a replica is added. It returns 0 if replicaCount is not strictly positive.
+func ReplicaOf(key SamplingKey, replicaCount int) int {
+	return jumpHash(key.HashWithSeed(replicaHashSeed), replicaCount)
+}
+
+// DecisionFor makes a sampling decision for the provided [SamplingKey] using
+// the wrapped sampler if it is owned by this replica, and drops it otherwise.
+func (s *replicaSampler) DecisionFor(key SamplingKey) bool {
+	if ReplicaOf(key, s.replicaCount) != s.replicaID {
+		s.notOwnedDrops.Add(1)
+		return false
+	}
+	return s.sampler.DecisionFor(key)
+}
+
+// Stats returns the statistics of the wrapped sampler, with the keys owned by
+// other replicas counted as dropped.
+func (s *replicaSampler) Stats() SamplerStats {
+	stats, _ := StatsOf(s.sampler)
+	stats.Drops += s.notOwnedDrops.Load()
+	return stats
+}
+
+// Close releases the resources held by the wrapped sampler.
+func (s *replicaSampler) Close() error {
+	return CloseSampler(s.sampler)
+}
+
+// jumpHash implements the jump consistent hash algorithm described in
+// https://arxiv.org/abs/1406.2294, returning the bucket of the given key among
+// the given number of buckets.
+func jumpHash(key uint64, buckets int) int {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(max(0, b))
+}
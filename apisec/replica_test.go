@@ -0,0 +1,102 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+package apisec
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplicaSampler(t *testing.T) {
+	initTestVector()
+	keys := testVector[:4_096]
+
+	t.Run("NewReplicaSampler", func(t *testing.T) {
+		sampler := &nullSampler{}
+		require.PanicsWithError(t, "NewReplicaSampler: replica ID must be in [0, 3), but was 3", func() { NewReplicaSampler(3, 3, sampler) })
+		require.PanicsWithError(t, "NewReplicaSampler: replica ID must be in [0, 3), but was -1", func() { NewReplicaSampler(-1, 3, sampler) })
+		require.PanicsWithError(t, "NewReplicaSampler: replica ID must be in [0, 0), but was 0", func() { NewReplicaSampler(0, 0, sampler) })
+		require.Same(t, sampler, NewReplicaSampler(0, 1, sampler))
+	})
+
+	t.Run("single-owner", func(t *testing.T) {
+		const replicaCount = 3
+		fakeTime := time.Now().Unix()
+		clock := func() int64 { return fakeTime }
+		replicas := make([]Sampler, replicaCount)
+		for id := range replicas {
+			replicas[id] = NewReplicaSampler(id, replicaCount, newSampler(30*time.Second, clock))
+		}
+
+		owned := make([]int, replicaCount)
+		for _, key := range keys {
+			var keptBy []int
+			for id, replica := range replicas {
+				if replica.DecisionFor(key) {
+					keptBy = append(keptBy, id)
+				}
+			}
+			// Some keys of the test vector hash the same, and were kept already
+			if len(keptBy) == 0 {
+				continue
+			}
+			require.Equal(t, []int{ReplicaOf(key, replicaCount)}, keptBy, "%+v", key)
+			owned[keptBy[0]]++
+		}
+
+		// Keys are evenly distributed across replicas
+		for id, count := range owned {
+			assert.InDelta(t, len(keys)/replicaCount, count, float64(len(keys))*.05, "replica %d", id)
+		}
+
+		stats, ok := StatsOf(replicas[0])
+		require.True(t, ok)
+		assert.EqualValues(t, len(keys), stats.Keeps+stats.Drops)
+		assert.EqualValues(t, owned[0], stats.Keeps)
+		assert.EqualValues(t, owned[0], stats.LiveEntries)
+	})
+
+	t.Run("consistent", func(t *testing.T) {
+		// Adding a replica only moves the keys it now owns
+		moved := 0
+		for _, key := range keys {
+			before, after := ReplicaOf(key, 4), ReplicaOf(key, 5)
+			if before != after {
+				require.Equal(t, 4, after, "%+v", key)
+				moved++
+			}
+		}
+		assert.InDelta(t, len(keys)/5, moved, float64(len(keys))*.05)
+	})
+
+	t.Run("stable", func(t *testing.T) {
+		// These values must never change, as all the replicas must agree on them,
+		// regardless of their architecture or release.
+		for _, tc := range []struct {
+			key      SamplingKey
+			expected []int
+		}{
+			{
+				key:      SamplingKey{Method: http.MethodGet, Route: "/users/{id}", StatusCode: http.StatusOK},
+				expected: []int{0, 0, 0, 2, 2, 2, 2, 2, 2, 2, 99},
+			},
+			{
+				key:      SamplingKey{Method: http.MethodPost, Route: "/login", StatusCode: http.StatusFound},
+				expected: []int{0, 0, 1, 1, 3, 4, 5, 6, 6, 13, 55},
+			},
+		} {
+			replicas := make([]int, 0, len(tc.expected))
+			for _, replicaCount := range []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 16, 100} {
+				replicas = append(replicas, ReplicaOf(tc.key, replicaCount))
+			}
+			assert.Equal(t, tc.expected, replicas, "%+v", tc.key)
+		}
+	})
+}
@@ -0,0 +1,138 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+package apisec
+
+import "strings"
+
+// Placeholders replacing the high-cardinality path segments collapsed by
+// [NormalizeRoute].
+const (
+	// RouteIntPlaceholder replaces numeric path segments (e.g. `123`).
+	RouteIntPlaceholder = "{int}"
+	// RouteUUIDPlaceholder replaces UUID path segments (e.g.
+	// `123e4567-e89b-12d3-a456-426614174000`).
+	RouteUUIDPlaceholder = "{uuid}"
+	// RouteHexPlaceholder replaces hexadecimal path segments of at least
+	// [minHexSegmentLength] characters, such as hashes or object IDs (e.g.
+	// `5f2b8c1e9a7d4e3f`).
+	RouteHexPlaceholder = "{hex}"
+	// RouteTokenPlaceholder replaces long path segments mixing letters and
+	// digits, such as tokens or encoded IDs (e.g. `dGhpc2lzYXRva2VuMTIz`).
+	RouteTokenPlaceholder = "{token}"
+)
+
+const (
+	// minHexSegmentLength is the minimum length of hexadecimal path segments
+	// replaced by [RouteHexPlaceholder], so that short words made of
+	// hexadecimal letters (e.g. `cafe`) are preserved.
+	minHexSegmentLength = 16
+	// minTokenSegmentLength is the minimum length of path segments replaced by
+	// [RouteTokenPlaceholder].
+	minTokenSegmentLength = 20
+)
+
+// NormalizeRoute returns the given raw URL path with its high-cardinality
+// segments collapsed into placeholders, so that it can be used as the
+// [SamplingKey.Route] when the framework does not provide the route of the
+// request. For example, `/users/123/orders/123e4567-e89b-12d3-a456-426614174000`
+// becomes `/users/{int}/orders/{uuid}`. The query string and fragment, if any,
+// are removed. The path is returned as-is if it has no such segments.
+func NormalizeRoute(path string) string {
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+
+	var (
+		sb      strings.Builder
+		written int // The length of the prefix of path already written to sb
+		start   int // The start of the current segment
+	)
+	for start <= len(path) {
+		end := strings.IndexByte(path[start:], '/')
+		if end < 0 {
+			end = len(path)
+		} else {
+			end += start
+		}
+
+		if placeholder := segmentPlaceholder(path[start:end]); placeholder != "" {
+			if written == 0 {
+				sb.Grow(len(path))
+			}
+			sb.WriteString(path[written:start])
+			sb.WriteString(placeholder)
+			written = end
+		}
+		start = end + 1
+	}
+
+	if written == 0 {
+		return path
+	}
+	sb.WriteString(path[written:])
+	return sb.String()
+}
+
+// segmentPlaceholder returns the placeholder replacing the given path segment,
+// or an empty string if it should be preserved.
+func segmentPlaceholder(segment string) string {
+	if segment == "" {
+		return ""
+	}
+
+	var digits, hexLetters, letters, others int
+	for i := range len(segment) {
+		switch c := segment[i]; {
+		case c >= '0' && c <= '9':
+			digits++
+		case c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F':
+			hexLetters++
+		case c >= 'g' && c <= 'z' || c >= 'G' && c <= 'Z':
+			letters++
+		case c == '-' || c == '_':
+			others++
+		default:
+			// Segments with any other character, such as `.` in file names, are
+			// not considered to be identifiers.
+			return ""
+		}
+	}
+
+	switch {
+	case digits == len(segment):
+		return RouteIntPlaceholder
+	case isUUID(segment):
+		return RouteUUIDPlaceholder
+	case digits > 0 && digits+hexLetters == len(segment) && len(segment) >= minHexSegmentLength:
+		return RouteHexPlaceholder
+	case digits > 0 && hexLetters+letters > 0 && len(segment) >= minTokenSegmentLength:
+		return RouteTokenPlaceholder
+	default:
+		return ""
+	}
+}
+
+// isUUID returns true if the given string is a UUID in its canonical textual
+// representation, regardless of its version.
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := range len(s) {
+		c := s[i]
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
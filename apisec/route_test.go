@@ -0,0 +1,49 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+package apisec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeRoute(t *testing.T) {
+	for path, expected := range map[string]string{
+		"":                               "",
+		"/":                              "/",
+		"/users":                         "/users",
+		"/users/":                        "/users/",
+		"/users/123":                     "/users/{int}",
+		"/users/123/":                    "/users/{int}/",
+		"/users/123/orders/456":          "/users/{int}/orders/{int}",
+		"123/users":                      "{int}/users",
+		"/v1/users/42?expand=orders#top": "/v1/users/{int}",
+		"/users/123e4567-e89b-12d3-a456-426614174000":        "/users/{uuid}",
+		"/users/123E4567-E89B-12D3-A456-426614174000/orders": "/users/{uuid}/orders",
+		"/users/123e4567e89b12d3a456426614174000":            "/users/{hex}",
+		"/commits/da39a3ee5e6b4b0d3255bfef95601890afd80709":  "/commits/{hex}",
+		"/objects/5f2b8c1e9a7d4e3f":                          "/objects/{hex}",
+		"/reset/dGhpc2lzYXRva2VuMTIz":                        "/reset/{token}",
+		"/reset/eyJhbGciOiJIUzI1NiJ9_abc-123":                "/reset/{token}",
+		// Malformed UUIDs are still high-cardinality tokens
+		"/users/123e4567-e89b-12d3-a456-42661417400": "/users/{token}",
+		// Segments that are not identifiers are preserved
+		"/v2/api":                         "/v2/api",
+		"/cafe/deadbeef":                  "/cafe/deadbeef",
+		"/deadbeefdeadbeefdeadbeef":       "/deadbeefdeadbeefdeadbeef",
+		"/5f2b8c1e9a7d":                   "/5f2b8c1e9a7d",
+		"/api/ui/frontend_telemetry":      "/api/ui/frontend_telemetry",
+		"/static/app.5f2b8c1e9a7d4e3f.js": "/static/app.5f2b8c1e9a7d4e3f.js",
+		"/users/{id}":                     "/users/{id}",
+	} {
+		assert.Equal(t, expected, NormalizeRoute(path), "NormalizeRoute(%q)", path)
+	}
+
+	t.Run("no-allocations", func(t *testing.T) {
+		assert.Zero(t, testing.AllocsPerRun(100, func() { _ = NormalizeRoute("/api/v1/users/me") }))
+	})
+}
@@ -6,10 +6,11 @@
 package apisec
 
 import (
-	"encoding/binary"
-	"hash/fnv"
+	"io"
+	"sync/atomic"
 	"time"
 
+	"github.com/DataDog/appsec-internal-go/apisec/internal/config"
 	"github.com/DataDog/appsec-internal-go/apisec/internal/timed"
 	"github.com/DataDog/appsec-internal-go/limiter"
 )
@@ -19,13 +20,45 @@ type (
 		DecisionFor(SamplingKey) bool
 	}
 
+	// StatsReporter is implemented by samplers that keep track of their
+	// sampling decisions. Use [StatsOf] to get the statistics of any [Sampler].
+	StatsReporter interface {
+		Stats() SamplerStats
+	}
+
+	// SamplerStats is a snapshot of the counters of a [Sampler], suitable for
+	// reporting as telemetry metrics.
+	SamplerStats struct {
+		// Keeps is the number of KEEP decisions made by the sampler.
+		Keeps uint64
+		// Drops is the number of DROP decisions made by the sampler, including
+		// OverflowDrops and WastedRateDrops.
+		Drops uint64
+		// OverflowDrops is the number of DROP decisions made because the
+		// sampler could not track any more keys.
+		OverflowDrops uint64
+		// WastedRateDrops is the number of DROP decisions made by rate-limited
+		// samplers after consuming part of their rate, because the key was
+		// concurrently sampled or could not be tracked. The rate consumed by
+		// these requests is lost.
+		WastedRateDrops uint64
+		// Rebuilds is the number of times the sampler has evicted old keys.
+		Rebuilds uint64
+		// LiveEntries is the number of keys currently tracked by the sampler.
+		LiveEntries int
+	}
+
 	timedSetSampler timed.LRU
 
 	proxySampler struct {
 		limiter limiter.Limiter
+		keeps   atomic.Uint64
+		drops   atomic.Uint64
 	}
 
-	nullSampler struct{}
+	nullSampler struct {
+		drops atomic.Uint64
+	}
 
 	SamplingKey struct {
 		// Method is the value of the http.method span tag
@@ -34,11 +67,48 @@ type (
 		Route string
 		// StatusCode is the value of the http.status_code span tag
 		StatusCode int
+
+		// The following dimensions are optional, and only taken into account
+		// when not empty, so that keys not using them are sampled as before.
+
+		// Service is the name of the service handling the request, allowing to
+		// tell apart the services behind a shared proxy.
+		Service string
+		// Host is the value of the Host header of the request, allowing to tell
+		// apart the virtual hosts served by a shared proxy.
+		Host string
+		// GraphQLOperation is the name of the GraphQL operation of the request,
+		// allowing to tell apart the operations served by a single route.
+		GraphQLOperation string
+		// GRPCMethod is the full name of the gRPC method of the request (e.g.
+		// `/package.Service/Method`).
+		GRPCMethod string
+		// ContentType is the media type of the request body, without its
+		// parameters (e.g. `application/json`).
+		ContentType string
+	}
+
+	// SamplerOption is an optional setting of the samplers created by
+	// [NewSamplerWithInterval].
+	SamplerOption func(*samplerConfig)
+
+	samplerConfig struct {
+		capacity   int
+		resolution time.Duration
 	}
 
 	clockFunc = func() int64
 )
 
+const (
+	// DefaultCapacity is the default number of keys tracked by the samplers
+	// created by [NewSamplerWithInterval].
+	DefaultCapacity = config.MaxItemCount
+	// MaxCapacity is the largest supported number of keys tracked by the
+	// samplers created by [NewSamplerWithInterval].
+	MaxCapacity = config.MaxItemCountLimit
+)
+
 // NewProxySampler creates a new sampler suitable for proxy environments where the sampling decision
 // is not based on the request's properties, but on a rate.
 func NewProxySampler(rate int, interval time.Duration) Sampler {
@@ -53,15 +123,58 @@ func NewProxySampler(rate int, interval time.Duration) Sampler {
 	}
 }
 
+// WithCapacity sets the number of keys tracked by the sampler, past which the
+// least recently sampled keys are evicted. Values that are not strictly
+// positive are ignored, and values greater than [MaxCapacity] are reduced to
+// it.
+func WithCapacity(capacity int) SamplerOption {
+	return func(cfg *samplerConfig) {
+		if capacity > 0 {
+			cfg.capacity = min(capacity, MaxCapacity)
+		}
+	}
+}
+
+// WithMillisecondResolution makes the sampler track sampling times at
+// millisecond resolution instead of second resolution, which allows using
+// sub-second intervals. This is the default for intervals that are not a whole
+// number of seconds.
+func WithMillisecondResolution() SamplerOption {
+	return func(cfg *samplerConfig) {
+		cfg.resolution = time.Millisecond
+	}
+}
+
 // NewSamplerWithInterval returns a new [*Sampler] with the specified interval.
-func NewSamplerWithInterval(interval time.Duration) Sampler {
-	return newSampler(interval, timed.UnixTime)
+func NewSamplerWithInterval(interval time.Duration, opts ...SamplerOption) Sampler {
+	return newSampler(interval, nil, opts...)
 }
 
 // newSampler allows creating a new [*Sampler] with custom clock function,
-// which is useful for testing.
-func newSampler(interval time.Duration, clock clockFunc) Sampler {
-	return (*timedSetSampler)(timed.NewLRU(interval, clock))
+// which is useful for testing. The clock must return timestamps in the unit of
+// the sampler's resolution. When nil, the current Unix time is used.
+func newSampler(interval time.Duration, clock clockFunc, opts ...SamplerOption) Sampler {
+	cfg := newSamplerConfig(interval, opts)
+	if clock == nil {
+		clock = timed.UnixTime
+		if cfg.resolution == time.Millisecond {
+			clock = timed.UnixMilliTime
+		}
+	}
+	return (*timedSetSampler)(timed.NewLRU(interval, clock, timed.WithMaxItemCount(cfg.capacity), timed.WithResolution(cfg.resolution)))
+}
+
+// newSamplerConfig returns the configuration of a sampler with the given
+// interval and options.
+func newSamplerConfig(interval time.Duration, opts []SamplerOption) samplerConfig {
+	cfg := samplerConfig{capacity: DefaultCapacity, resolution: time.Second}
+	if interval%time.Second != 0 {
+		cfg.resolution = time.Millisecond
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
 }
 
 // DecisionFor makes a sampling decision for the provided [SamplingKey]. If it
@@ -70,29 +183,158 @@ func newSampler(interval time.Duration, clock clockFunc) Sampler {
 // dropped, and the caller should short-circuit without extending further
 // effort.
 func (s *timedSetSampler) DecisionFor(key SamplingKey) bool {
-	keyHash := key.hash()
+	keyHash := key.Hash()
 	return (*timed.LRU)(s).Hit(keyHash)
 }
 
+// Stats returns the statistics of this sampler.
+func (s *timedSetSampler) Stats() SamplerStats {
+	stats := (*timed.LRU)(s).Stats()
+	return SamplerStats{
+		Keeps:         stats.Keeps,
+		Drops:         stats.Drops,
+		OverflowDrops: stats.OverflowDrops,
+		Rebuilds:      stats.Rebuilds,
+		LiveEntries:   stats.LiveEntries,
+	}
+}
+
 func (s *proxySampler) DecisionFor(_ SamplingKey) bool {
-	return s.limiter.Allow()
+	if s.limiter.Allow() {
+		s.keeps.Add(1)
+		return true
+	}
+	s.drops.Add(1)
+	return false
+}
+
+// Stats returns the statistics of this sampler.
+func (s *proxySampler) Stats() SamplerStats {
+	return SamplerStats{Keeps: s.keeps.Load(), Drops: s.drops.Load()}
 }
 
 func (s *nullSampler) DecisionFor(_ SamplingKey) bool {
+	s.drops.Add(1)
 	return false
 }
 
-// hash returns a hash of the key. Given the same seed, it always produces the
-// same output. If the seed changes, the output is likely to change as well.
-func (k SamplingKey) hash() uint64 {
-	fnv := fnv.New64()
+// Stats returns the statistics of this sampler.
+func (s *nullSampler) Stats() SamplerStats {
+	return SamplerStats{Drops: s.drops.Load()}
+}
 
-	_, _ = fnv.Write([]byte(k.Method))
-	_, _ = fnv.Write([]byte(k.Route))
+// Close releases the resources held by this sampler, of which there are none.
+func (s *timedSetSampler) Close() error {
+	return nil
+}
+
+// Close stops the rate limiter of this sampler.
+func (s *proxySampler) Close() error {
+	stopLimiter(s.limiter)
+	return nil
+}
+
+// Close releases the resources held by this sampler, of which there are none.
+func (s *nullSampler) Close() error {
+	return nil
+}
+
+// stopLimiter stops the given limiter, if it is a [limiter.TokenTicker].
+func stopLimiter(l limiter.Limiter) {
+	if ticker, ok := l.(*limiter.TokenTicker); ok {
+		ticker.Stop()
+	}
+}
+
+// CloseSampler releases the resources held by the given sampler, if it
+// implements [io.Closer]. The sampler must no longer be used afterwards.
+func CloseSampler(s Sampler) error {
+	closer, ok := s.(io.Closer)
+	if !ok {
+		return nil
+	}
+	return closer.Close()
+}
+
+// StatsOf returns the statistics of the given sampler, if it implements
+// [StatsReporter].
+func StatsOf(s Sampler) (SamplerStats, bool) {
+	reporter, ok := s.(StatsReporter)
+	if !ok {
+		return SamplerStats{}, false
+	}
+	return reporter.Stats(), true
+}
+
+// Hash returns a hash of the key, suitable for coordinating sampling decisions
+// across processes: it only depends on the value of the key, and is the same on
+// all architectures and across releases. It is equivalent to HashWithSeed(0).
+func (k SamplingKey) Hash() uint64 {
+	return k.HashWithSeed(0)
+}
+
+// HashWithSeed returns a hash of the key, using the given seed. Given the same
+// seed, it always produces the same output. If the seed changes, the output is
+// likely to change as well. Samplers always use [SamplingKey.Hash]; other seeds
+// allow deriving hashes that are not correlated with it, as [ReplicaOf] does.
+//
+// The hash is the 64-bit FNV-1a hash of the seed, in little-endian byte order,
+// followed by the length-prefixed method and route, and the status code. Each
+// optional dimension that is not empty follows, prefixed by a distinct tag byte
+// and its length, so that the hash of a key without optional dimensions only
+// depends on its method, route and status code, and so that values cannot be
+// mistaken for one another (e.g. `GET` followed by `/x` and `GE` followed by
+// `T/x`).
+func (k SamplingKey) HashWithSeed(seed uint64) uint64 {
+	h := fnvHash(fnvOffset64)
+	for i := range 8 {
+		h = h.writeByte(byte(seed >> (8 * i)))
+	}
+	h = h.writeString(k.Method)
+	h = h.writeString(k.Route)
+	h = h.writeUvarint(uint64(k.StatusCode))
+
+	for tag, value := range [...]string{k.Service, k.Host, k.GraphQLOperation, k.GRPCMethod, k.ContentType} {
+		if value == "" {
+			continue
+		}
+		h = h.writeByte(byte(tag + 1))
+		h = h.writeString(value)
+	}
+
+	return uint64(h)
+}
 
-	var bytes [2]byte
-	binary.NativeEndian.PutUint16(bytes[:], uint16(k.StatusCode))
-	_, _ = fnv.Write(bytes[:])
+// fnvHash is the state of a 64-bit FNV-1a hash, implemented here so that
+// hashing keys does not allocate.
+type fnvHash uint64
 
-	return fnv.Sum64()
+const (
+	fnvOffset64 = 14695981039346656037
+	fnvPrime64  = 1099511628211
+)
+
+// writeByte returns the hash state updated with the given byte.
+func (h fnvHash) writeByte(b byte) fnvHash {
+	return (h ^ fnvHash(b)) * fnvPrime64
+}
+
+// writeUvarint returns the hash state updated with the given value, encoded as
+// a varint.
+func (h fnvHash) writeUvarint(value uint64) fnvHash {
+	for value >= 0x80 {
+		h = h.writeByte(byte(value) | 0x80)
+		value >>= 7
+	}
+	return h.writeByte(byte(value))
+}
+
+// writeString returns the hash state updated with the given string, prefixed
+// with its length.
+func (h fnvHash) writeString(value string) fnvHash {
+	h = h.writeUvarint(uint64(len(value)))
+	for i := range len(value) {
+		h = h.writeByte(value[i])
+	}
+	return h
 }
@@ -8,6 +8,7 @@ package apisec
 import (
 	"context"
 	"fmt"
+	"io"
 	"math/rand"
 	"net/http"
 	"runtime"
@@ -19,6 +20,7 @@ import (
 	"github.com/DataDog/appsec-internal-go/apisec/internal/config"
 	"github.com/DataDog/appsec-internal-go/apisec/internal/timed/testutil"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
 )
 
 func TestSampler(t *testing.T) {
@@ -137,7 +139,7 @@ func TestSamplingKeyHash(t *testing.T) {
 	t.Run("collisions-are-infrequent", func(t *testing.T) {
 		hashes := make(map[uint64]struct{}, len(testVector))
 		for _, key := range testVector {
-			hashes[key.hash()] = struct{}{}
+			hashes[key.Hash()] = struct{}{}
 		}
 
 		// Validates that the hash function results in less than 1% collisions. This
@@ -150,7 +152,7 @@ func TestSamplingKeyHash(t *testing.T) {
 	t.Run("distribution-is-uniform-on-buckets", func(t *testing.T) {
 		buckets := make(map[int]struct{}, len(testVector))
 		for _, key := range testVector {
-			buckets[int(key.hash()%config.MaxItemCount)] = struct{}{}
+			buckets[int(key.Hash()%config.MaxItemCount)] = struct{}{}
 		}
 
 		// Validates that the hash function results in hitting at least 80% of the
@@ -159,8 +161,208 @@ func TestSamplingKeyHash(t *testing.T) {
 		assert.GreaterOrEqual(t, float64(len(buckets))/float64(config.MaxItemCount), .80,
 			"the hash function should slot into 50% of the buckets at least")
 	})
+
+	t.Run("optional-dimensions", func(t *testing.T) {
+		base := SamplingKey{Method: http.MethodPost, Route: "/graphql", StatusCode: http.StatusOK}
+
+		hashes := map[uint64]SamplingKey{base.Hash(): base}
+		for _, key := range []SamplingKey{
+			{Method: base.Method, Route: base.Route, StatusCode: base.StatusCode, Service: "checkout"},
+			{Method: base.Method, Route: base.Route, StatusCode: base.StatusCode, Service: "cart"},
+			{Method: base.Method, Route: base.Route, StatusCode: base.StatusCode, Host: "checkout"},
+			{Method: base.Method, Route: base.Route, StatusCode: base.StatusCode, GraphQLOperation: "GetCart"},
+			{Method: base.Method, Route: base.Route, StatusCode: base.StatusCode, GraphQLOperation: "AddToCart"},
+			{Method: base.Method, Route: base.Route, StatusCode: base.StatusCode, GRPCMethod: "/shop.Cart/Get"},
+			{Method: base.Method, Route: base.Route, StatusCode: base.StatusCode, ContentType: "application/json"},
+			{Method: base.Method, Route: base.Route, StatusCode: base.StatusCode, Service: "checkout", GraphQLOperation: "GetCart"},
+		} {
+			hash := key.Hash()
+			assert.NotContains(t, hashes, hash, "%+v collides with %+v", key, hashes[hash])
+			hashes[hash] = key
+		}
+	})
+
+	t.Run("stable", func(t *testing.T) {
+		// These values must never change, as they may be used to coordinate
+		// sampling decisions across processes, architectures and releases.
+		assert.Equal(t, uint64(0xc885ccdc03990c97), SamplingKey{}.Hash())
+		assert.Equal(t, uint64(0x4edca58d26ae5601), SamplingKey{Method: http.MethodGet, Route: "/users/{id}", StatusCode: http.StatusOK}.Hash())
+		assert.Equal(t, uint64(0x8fe738b340260f8f), SamplingKey{Method: http.MethodGet, Route: "/users/{id}", StatusCode: http.StatusOK}.HashWithSeed(42))
+		assert.Equal(t, uint64(0xc87728e0480904b9), SamplingKey{
+			Method:           http.MethodPost,
+			Route:            "/graphql",
+			StatusCode:       http.StatusOK,
+			Service:          "checkout",
+			GraphQLOperation: "GetCart",
+		}.Hash())
+	})
+
+	t.Run("seed", func(t *testing.T) {
+		key := SamplingKey{Method: http.MethodGet, Route: "/", StatusCode: http.StatusOK}
+		assert.Equal(t, key.Hash(), key.HashWithSeed(0))
+		assert.Equal(t, key.HashWithSeed(1337), key.HashWithSeed(1337))
+		assert.NotEqual(t, key.HashWithSeed(1337), key.HashWithSeed(1338))
+		assert.NotEqual(t, key.Hash(), key.HashWithSeed(1<<63))
+	})
+
+	t.Run("length-prefixed", func(t *testing.T) {
+		for _, keys := range [][2]SamplingKey{
+			{{Method: "GET", Route: "/x"}, {Method: "GE", Route: "T/x"}},
+			{{Method: "GET", Route: "/x"}, {Method: "GET/x"}},
+			{{Route: "/x", Service: "a", Host: "b"}, {Route: "/x", Service: "ab"}},
+			{{Route: "/x", Service: "a"}, {Route: "/x", Host: "a"}},
+			{{Route: "/x", Service: "\x02a"}, {Route: "/x", Host: "a"}},
+			{{Route: "/x", StatusCode: 200}, {Route: "/x", StatusCode: 200 + 1<<16}},
+		} {
+			assert.NotEqual(t, keys[0].Hash(), keys[1].Hash(), "%+v collides with %+v", keys[0], keys[1])
+		}
+	})
+
+	t.Run("no-allocations", func(t *testing.T) {
+		key := SamplingKey{Method: http.MethodGet, Route: "/users/{id}", StatusCode: http.StatusOK, Service: "users"}
+		assert.Zero(t, testing.AllocsPerRun(100, func() { _ = key.Hash() }))
+	})
 }
 
+func TestSamplerStats(t *testing.T) {
+	key := SamplingKey{Method: http.MethodGet, Route: "/", StatusCode: http.StatusOK}
+
+	t.Run("timed", func(t *testing.T) {
+		fakeTime := time.Now().Unix()
+		subject := newSampler(30*time.Second, func() int64 { return fakeTime })
+		assert.True(t, subject.DecisionFor(key))
+		assert.False(t, subject.DecisionFor(key))
+		assert.True(t, subject.DecisionFor(SamplingKey{Method: http.MethodPost, Route: "/", StatusCode: http.StatusOK}))
+
+		stats, ok := StatsOf(subject)
+		assert.True(t, ok)
+		assert.Equal(t, SamplerStats{Keeps: 2, Drops: 1, LiveEntries: 2}, stats)
+	})
+
+	t.Run("proxy", func(t *testing.T) {
+		subject := NewProxySampler(1, time.Hour)
+		defer CloseSampler(subject)
+		assert.True(t, subject.DecisionFor(key))
+		assert.False(t, subject.DecisionFor(key))
+
+		stats, ok := StatsOf(subject)
+		assert.True(t, ok)
+		assert.Equal(t, SamplerStats{Keeps: 1, Drops: 1}, stats)
+	})
+
+	t.Run("null", func(t *testing.T) {
+		subject := NewProxySampler(0, time.Hour)
+		assert.False(t, subject.DecisionFor(key))
+
+		stats, ok := StatsOf(subject)
+		assert.True(t, ok)
+		assert.Equal(t, SamplerStats{Drops: 1}, stats)
+	})
+
+	t.Run("custom", func(t *testing.T) {
+		_, ok := StatsOf(alwaysKeep{})
+		assert.False(t, ok)
+	})
+}
+
+func TestSamplerClose(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	for name, newSampler := range map[string]func() Sampler{
+		"timed":    func() Sampler { return NewSamplerWithInterval(30 * time.Second) },
+		"proxy":    func() Sampler { return NewProxySampler(10, time.Minute) },
+		"null":     func() Sampler { return NewProxySampler(0, time.Minute) },
+		"keyed":    func() Sampler { return NewKeyedProxySampler(10, time.Minute, 30*time.Second) },
+		"adaptive": func() Sampler { return NewAdaptiveSampler(30*time.Second, time.Hour) },
+		"overrides": func() Sampler {
+			return NewSamplerWithOverrides(30*time.Second, []IntervalOverride{{Route: "/health", Interval: NeverSample}})
+		},
+		"combinators": func() Sampler {
+			return Any(
+				ForceKeep(func(SamplingKey) bool { return false }),
+				All(NewProxySampler(10, time.Minute), Not(NewKeyedProxySampler(10, time.Minute, 30*time.Second))),
+			)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			subject := newSampler()
+			subject.DecisionFor(SamplingKey{Method: http.MethodGet, Route: "/", StatusCode: http.StatusOK})
+			assert.Implements(t, (*io.Closer)(nil), subject)
+			assert.NoError(t, CloseSampler(subject))
+			// Closing again is harmless
+			assert.NoError(t, CloseSampler(subject))
+		})
+	}
+
+	t.Run("custom", func(t *testing.T) {
+		assert.NoError(t, CloseSampler(alwaysKeep{}))
+	})
+}
+
+func TestSamplerCapacity(t *testing.T) {
+	fakeTime := time.Now().Unix()
+	clock := func() int64 { return fakeTime }
+
+	for _, tc := range []struct {
+		name     string
+		opts     []SamplerOption
+		capacity int
+	}{
+		{name: "default", capacity: DefaultCapacity},
+		{name: "custom", opts: []SamplerOption{WithCapacity(10)}, capacity: 10},
+		{name: "invalid", opts: []SamplerOption{WithCapacity(-1)}, capacity: DefaultCapacity},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			subject := newSampler(time.Hour, clock, tc.opts...)
+			for i := range tc.capacity {
+				assert.True(t, subject.DecisionFor(SamplingKey{Method: http.MethodGet, Route: "/", StatusCode: i}))
+			}
+			stats, _ := StatsOf(subject)
+			assert.Equal(t, tc.capacity, stats.LiveEntries)
+			assert.Zero(t, stats.Rebuilds)
+		})
+	}
+}
+
+func TestSamplerResolution(t *testing.T) {
+	key := SamplingKey{Method: http.MethodGet, Route: "/", StatusCode: http.StatusOK}
+
+	for _, tc := range []struct {
+		name       string
+		interval   time.Duration
+		opts       []SamplerOption
+		resolution time.Duration
+	}{
+		{name: "seconds", interval: 30 * time.Second, resolution: time.Second},
+		{name: "sub-second", interval: 100 * time.Millisecond, resolution: time.Millisecond},
+		{name: "fractional", interval: 1500 * time.Millisecond, resolution: time.Millisecond},
+		{name: "explicit", interval: 2 * time.Second, opts: []SamplerOption{WithMillisecondResolution()}, resolution: time.Millisecond},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.resolution, newSamplerConfig(tc.interval, tc.opts).resolution)
+
+			fakeTime := time.Now().UnixNano() / int64(tc.resolution)
+			subject := newSampler(tc.interval, func() int64 { return fakeTime }, tc.opts...)
+			assert.True(t, subject.DecisionFor(key))
+			fakeTime += int64(tc.interval/tc.resolution) - 1
+			assert.False(t, subject.DecisionFor(key))
+			fakeTime++
+			assert.True(t, subject.DecisionFor(key))
+		})
+	}
+
+	t.Run("real-clock", func(t *testing.T) {
+		subject := NewSamplerWithInterval(10 * time.Millisecond)
+		assert.True(t, subject.DecisionFor(key))
+		assert.False(t, subject.DecisionFor(key))
+		assert.Eventually(t, func() bool { return subject.DecisionFor(key) }, time.Second, time.Millisecond)
+	})
+}
+
+type alwaysKeep struct{}
+
+func (alwaysKeep) DecisionFor(SamplingKey) bool { return true }
+
 func BenchmarkSampler(b *testing.B) {
 	initTestVector()
 
@@ -0,0 +1,296 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+package apisec
+
+import (
+	"bytes"
+	"cmp"
+	"container/list"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type (
+	// Schema is the structure of an extracted API Security schema, flattened as
+	// the types of its fields (see [ParseSchema]), indexed by their paths.
+	Schema map[string]string
+
+	// SchemaDiff is the structural difference between two versions of the
+	// schemas of a key, as computed by [SchemaCache.Update]. Its fields are
+	// sorted by schema name and path.
+	SchemaDiff struct {
+		// Added are the fields only present in the new schemas.
+		Added []SchemaField `json:"added,omitempty"`
+		// Removed are the fields only present in the old schemas.
+		Removed []SchemaField `json:"removed,omitempty"`
+		// Changed are the fields whose type changed.
+		Changed []SchemaChange `json:"changed,omitempty"`
+	}
+
+	// SchemaField is a field of a named schema.
+	SchemaField struct {
+		// Schema is the name of the schema the field belongs to (e.g.
+		// `_dd.appsec.s.req.body`).
+		Schema string `json:"schema"`
+		// Path is the path of the field in the schema.
+		Path string `json:"path"`
+		// Type is the type of the field.
+		Type string `json:"type"`
+	}
+
+	// SchemaChange is a field of a named schema whose type changed.
+	SchemaChange struct {
+		// Schema is the name of the schema the field belongs to.
+		Schema string `json:"schema"`
+		// Path is the path of the field in the schema.
+		Path string `json:"path"`
+		// OldType is the previous type of the field.
+		OldType string `json:"old_type"`
+		// NewType is the new type of the field.
+		NewType string `json:"new_type"`
+	}
+
+	// SchemaCache stores the last schemas extracted for each [SamplingKey], so
+	// that only their changes are reported. It is bounded, the least recently
+	// updated keys being evicted first. It is safe for concurrent use.
+	SchemaCache struct {
+		mu sync.Mutex
+		// entries are the cached entries, indexed by key.
+		entries map[SamplingKey]*list.Element
+		// order is the list of the cached entries, from the most to the least
+		// recently updated.
+		order *list.List
+		// maxKeys is the maximum number of keys cached.
+		maxKeys int
+	}
+
+	schemaCacheEntry struct {
+		key SamplingKey
+		// schemas are the last schemas extracted for the key, by name. Neither
+		// the map nor its schemas are ever modified once cached.
+		schemas map[string]Schema
+	}
+)
+
+// DefaultSchemaCacheSize is the default maximum number of keys cached by a
+// [SchemaCache].
+const DefaultSchemaCacheSize = 1_024
+
+// ErrInvalidSchema is returned by [ParseSchema] when the schema is malformed.
+var ErrInvalidSchema = errors.New("invalid schema")
+
+// schemaTypes are the names of the scalar types of the schemas, indexed by
+// their code.
+var schemaTypes = map[int]string{
+	0:  "unknown",
+	1:  "null",
+	2:  "boolean",
+	4:  "integer",
+	8:  "string",
+	16: "float",
+}
+
+// ParseSchema parses a schema in the compact JSON format produced by the WAF,
+// where each node is an array whose first element is either the code of a
+// scalar type (e.g. `[8]`), an object of the nodes of its fields (e.g.
+// `[{"id":[4]}]`), or an array of the nodes of its elements (e.g.
+// `[[[8]],{"len":2}]`); the other elements being metadata, which is ignored.
+//
+// The returned [Schema] has an entry for each node: objects and arrays have the
+// `object` and `array` types respectively. The path of the root node is empty,
+// object fields are appended as `.name` (or `["name"]` when the name is empty
+// or contains `.`, `[`, `]` or `"`), and array elements as `[]`. When the
+// elements of an array have different types at the same path, they are joined
+// in increasing order with `|` (e.g. `integer|string`).
+func ParseSchema(data []byte) (Schema, error) {
+	schema := make(Schema)
+	if err := schema.parse("", data); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+func (s Schema) parse(path string, data []byte) error {
+	var node []json.RawMessage
+	if err := json.Unmarshal(data, &node); err != nil || len(node) == 0 {
+		return fmt.Errorf("%w: expected a non-empty array at %q", ErrInvalidSchema, path)
+	}
+
+	switch head := bytes.TrimSpace(node[0]); {
+	case len(head) > 0 && head[0] == '{':
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(head, &fields); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidSchema, err)
+		}
+		s.add(path, "object")
+		for name, field := range fields {
+			if err := s.parse(schemaFieldPath(path, name), field); err != nil {
+				return err
+			}
+		}
+	case len(head) > 0 && head[0] == '[':
+		var elements []json.RawMessage
+		if err := json.Unmarshal(head, &elements); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidSchema, err)
+		}
+		s.add(path, "array")
+		for _, element := range elements {
+			if err := s.parse(path+"[]", element); err != nil {
+				return err
+			}
+		}
+	default:
+		var code *int
+		if err := json.Unmarshal(head, &code); err != nil || code == nil {
+			return fmt.Errorf("%w: expected a type code at %q", ErrInvalidSchema, path)
+		}
+		typ, found := schemaTypes[*code]
+		if !found {
+			typ = "type(" + strconv.Itoa(*code) + ")"
+		}
+		s.add(path, typ)
+	}
+	return nil
+}
+
+// add records the given type for the given path, joining it with the types
+// already recorded for it, if any.
+func (s Schema) add(path, typ string) {
+	existing, found := s[path]
+	if !found {
+		s[path] = typ
+		return
+	}
+	types := strings.Split(existing, "|")
+	if i, found := slices.BinarySearch(types, typ); !found {
+		s[path] = strings.Join(slices.Insert(types, i, typ), "|")
+	}
+}
+
+// schemaFieldPath returns the path of the field with the given name in the
+// object at the given path.
+func schemaFieldPath(path, name string) string {
+	if name == "" || strings.ContainsAny(name, `.[]"`) {
+		return path + "[" + strconv.Quote(name) + "]"
+	}
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// DiffSchemas returns the structural difference between the old and new
+// versions of the schema with the given name.
+func DiffSchemas(name string, oldSchema, newSchema Schema) SchemaDiff {
+	var diff SchemaDiff
+	diff.add(name, oldSchema, newSchema)
+	diff.sort()
+	return diff
+}
+
+func (d *SchemaDiff) add(name string, oldSchema, newSchema Schema) {
+	for path, newType := range newSchema {
+		oldType, found := oldSchema[path]
+		switch {
+		case !found:
+			d.Added = append(d.Added, SchemaField{Schema: name, Path: path, Type: newType})
+		case oldType != newType:
+			d.Changed = append(d.Changed, SchemaChange{Schema: name, Path: path, OldType: oldType, NewType: newType})
+		}
+	}
+	for path, oldType := range oldSchema {
+		if _, found := newSchema[path]; !found {
+			d.Removed = append(d.Removed, SchemaField{Schema: name, Path: path, Type: oldType})
+		}
+	}
+}
+
+func (d *SchemaDiff) sort() {
+	compareFields := func(a, b SchemaField) int {
+		return cmp.Or(strings.Compare(a.Schema, b.Schema), strings.Compare(a.Path, b.Path))
+	}
+	slices.SortFunc(d.Added, compareFields)
+	slices.SortFunc(d.Removed, compareFields)
+	slices.SortFunc(d.Changed, func(a, b SchemaChange) int {
+		return cmp.Or(strings.Compare(a.Schema, b.Schema), strings.Compare(a.Path, b.Path))
+	})
+}
+
+// IsEmpty returns true if there is no difference between the schemas.
+func (d SchemaDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// NewSchemaCache returns a new, empty [SchemaCache] caching the schemas of at
+// most the given number of keys. If it is not strictly positive,
+// [DefaultSchemaCacheSize] is used instead.
+func NewSchemaCache(maxKeys int) *SchemaCache {
+	if maxKeys <= 0 {
+		maxKeys = DefaultSchemaCacheSize
+	}
+	return &SchemaCache{
+		entries: make(map[SamplingKey]*list.Element),
+		order:   list.New(),
+		maxKeys: maxKeys,
+	}
+}
+
+// Update stores the given named schemas as the last ones extracted for the
+// given key, and returns their difference with the previous ones. Schemas not
+// provided are left unchanged, as requests to an endpoint do not all have the
+// same parts (e.g. a body). If the key was not cached, all the fields are
+// reported as added. The returned boolean is true if there is any difference,
+// in which case the schemas should be reported. The schemas are copied, so that
+// the caller may modify them afterwards.
+func (c *SchemaCache) Update(key SamplingKey, schemas map[string]Schema) (SchemaDiff, bool) {
+	copies := make(map[string]Schema, len(schemas))
+	for name, schema := range schemas {
+		copies[name] = maps.Clone(schema)
+	}
+
+	// The cached schemas are never modified, but replaced by updated copies, so
+	// that the previous ones can be diffed without holding the lock.
+	c.mu.Lock()
+	var entry *schemaCacheEntry
+	if elem, found := c.entries[key]; found {
+		c.order.MoveToFront(elem)
+		entry = elem.Value.(*schemaCacheEntry)
+	} else {
+		if c.order.Len() >= c.maxKeys {
+			oldest := c.order.Back()
+			delete(c.entries, oldest.Value.(*schemaCacheEntry).key)
+			c.order.Remove(oldest)
+		}
+		entry = &schemaCacheEntry{key: key}
+		c.entries[key] = c.order.PushFront(entry)
+	}
+	previous := entry.schemas
+	updated := make(map[string]Schema, len(previous)+len(copies))
+	maps.Copy(updated, previous)
+	maps.Copy(updated, copies)
+	entry.schemas = updated
+	c.mu.Unlock()
+
+	var diff SchemaDiff
+	for name, schema := range copies {
+		diff.add(name, previous[name], schema)
+	}
+	diff.sort()
+	return diff, !diff.IsEmpty()
+}
+
+// Len returns the number of keys currently cached.
+func (c *SchemaCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
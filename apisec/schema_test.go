@@ -0,0 +1,210 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+package apisec
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchema(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		data     string
+		expected Schema
+	}{
+		{
+			name:     "scalar",
+			data:     `[8]`,
+			expected: Schema{"": "string"},
+		},
+		{
+			name:     "scalar-with-metadata",
+			data:     `[8, {"category": "pii", "type": "email"}]`,
+			expected: Schema{"": "string"},
+		},
+		{
+			name:     "unknown-type",
+			data:     `[32]`,
+			expected: Schema{"": "type(32)"},
+		},
+		{
+			name: "object",
+			data: `[{"id": [4], "name": [8], "address": [{"zip": [8], "geo": [[[16]], {"len": 2}]}]}]`,
+			expected: Schema{
+				"":              "object",
+				"id":            "integer",
+				"name":          "string",
+				"address":       "object",
+				"address.zip":   "string",
+				"address.geo":   "array",
+				"address.geo[]": "float",
+			},
+		},
+		{
+			name: "mixed-array",
+			data: `[[[4], [8], [{"a": [2]}], [{"a": [1], "b": [2]}]], {"len": 4, "truncated": true}]`,
+			expected: Schema{
+				"":     "array",
+				"[]":   "integer|object|string",
+				"[].a": "boolean|null",
+				"[].b": "boolean",
+			},
+		},
+		{
+			name:     "empty-array",
+			data:     `[[], {"len": 0}]`,
+			expected: Schema{"": "array"},
+		},
+		{
+			name: "escaped-names",
+			data: `[{"": [8], "a.b": [{"c": [4]}], "[0]": [2]}]`,
+			expected: Schema{
+				"":          "object",
+				`[""]`:      "string",
+				`["a.b"]`:   "object",
+				`["a.b"].c`: "integer",
+				`["[0]"]`:   "boolean",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			schema, err := ParseSchema([]byte(tc.data))
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, schema)
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, data := range []string{``, `8`, `[]`, `["string"]`, `[{"a": 8}]`, `[[8]]`, `[{"a": [[[]]]}]`, `[null]`} {
+			_, err := ParseSchema([]byte(data))
+			require.ErrorIs(t, err, ErrInvalidSchema, "%q", data)
+		}
+	})
+}
+
+func TestDiffSchemas(t *testing.T) {
+	oldSchema := Schema{"": "object", "id": "integer", "name": "string", "tags": "array", "tags[]": "string"}
+	newSchema := Schema{"": "object", "id": "string", "name": "string", "email": "string"}
+
+	diff := DiffSchemas("body", oldSchema, newSchema)
+	require.Equal(t, SchemaDiff{
+		Added: []SchemaField{{Schema: "body", Path: "email", Type: "string"}},
+		Removed: []SchemaField{
+			{Schema: "body", Path: "tags", Type: "array"},
+			{Schema: "body", Path: "tags[]", Type: "string"},
+		},
+		Changed: []SchemaChange{{Schema: "body", Path: "id", OldType: "integer", NewType: "string"}},
+	}, diff)
+	require.False(t, diff.IsEmpty())
+
+	require.True(t, DiffSchemas("body", newSchema, newSchema).IsEmpty())
+	require.Equal(t, SchemaDiff{Removed: []SchemaField{{Schema: "body", Path: "", Type: "string"}}}, DiffSchemas("body", Schema{"": "string"}, nil))
+}
+
+func TestSchemaCache(t *testing.T) {
+	key := SamplingKey{Method: http.MethodPost, Route: "/users", StatusCode: http.StatusCreated}
+	body := Schema{"": "object", "id": "integer"}
+	query := Schema{"": "object", "dry_run": "boolean"}
+
+	t.Run("Update", func(t *testing.T) {
+		subject := NewSchemaCache(10)
+
+		diff, changed := subject.Update(key, map[string]Schema{"body": body, "query": query})
+		require.True(t, changed)
+		require.Equal(t, SchemaDiff{Added: []SchemaField{
+			{Schema: "body", Path: "", Type: "object"},
+			{Schema: "body", Path: "id", Type: "integer"},
+			{Schema: "query", Path: "", Type: "object"},
+			{Schema: "query", Path: "dry_run", Type: "boolean"},
+		}}, diff)
+
+		// The same schemas are not reported again
+		diff, changed = subject.Update(key, map[string]Schema{"body": body, "query": query})
+		require.False(t, changed)
+		require.True(t, diff.IsEmpty())
+
+		// Schemas not provided are left unchanged
+		diff, changed = subject.Update(key, map[string]Schema{"body": body})
+		require.False(t, changed)
+		require.True(t, diff.IsEmpty())
+
+		// Only the changes are reported
+		diff, changed = subject.Update(key, map[string]Schema{"body": {"": "object", "id": "string"}, "query": query})
+		require.True(t, changed)
+		require.Equal(t, SchemaDiff{Changed: []SchemaChange{{Schema: "body", Path: "id", OldType: "integer", NewType: "string"}}}, diff)
+
+		// The cached schemas are not affected by changes made by the caller
+		body := Schema{"": "object", "id": "integer"}
+		_, changed = subject.Update(key, map[string]Schema{"body": body})
+		require.True(t, changed)
+		body["id"] = "string"
+		diff, changed = subject.Update(key, map[string]Schema{"body": {"": "object", "id": "integer"}})
+		require.False(t, changed)
+		require.True(t, diff.IsEmpty())
+
+		// Other keys are tracked separately
+		otherKey := key
+		otherKey.StatusCode = http.StatusBadRequest
+		_, changed = subject.Update(otherKey, map[string]Schema{"body": body})
+		require.True(t, changed)
+		require.Equal(t, 2, subject.Len())
+	})
+
+	t.Run("bounded", func(t *testing.T) {
+		subject := NewSchemaCache(2)
+		keyOf := func(i int) SamplingKey {
+			return SamplingKey{Method: http.MethodGet, Route: fmt.Sprintf("/%d", i), StatusCode: http.StatusOK}
+		}
+
+		for i := range 2 {
+			_, changed := subject.Update(keyOf(i), map[string]Schema{"body": body})
+			require.True(t, changed)
+		}
+		// Key 0 is now the most recently updated one, so key 1 gets evicted
+		_, changed := subject.Update(keyOf(0), map[string]Schema{"body": body})
+		require.False(t, changed)
+		_, changed = subject.Update(keyOf(2), map[string]Schema{"body": body})
+		require.True(t, changed)
+		require.Equal(t, 2, subject.Len())
+
+		_, changed = subject.Update(keyOf(0), map[string]Schema{"body": body})
+		require.False(t, changed)
+		_, changed = subject.Update(keyOf(1), map[string]Schema{"body": body})
+		require.True(t, changed)
+
+		require.Equal(t, DefaultSchemaCacheSize, NewSchemaCache(0).maxKeys)
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		subject := NewSchemaCache(10)
+		var (
+			wg      sync.WaitGroup
+			mu      sync.Mutex
+			changes int
+		)
+		for range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range 1_000 {
+					if _, changed := subject.Update(key, map[string]Schema{"body": body}); changed {
+						mu.Lock()
+						changes++
+						mu.Unlock()
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 1, changes)
+	})
+}
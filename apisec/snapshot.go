@@ -0,0 +1,119 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+package apisec
+
+import (
+	"errors"
+	"io"
+
+	"github.com/DataDog/appsec-internal-go/apisec/internal/timed"
+)
+
+// Snapshotter is implemented by samplers whose state can be persisted, for
+// instance on shutdown, and restored, for instance on startup, so that keys
+// sampled before a restart are not sampled again right away. Use
+// [WriteSnapshot] and [ReadSnapshot] to persist the state of any [Sampler].
+type Snapshotter interface {
+	// WriteTo writes a snapshot of the state of the sampler to w.
+	io.WriterTo
+	// ReadFrom restores the state of the sampler from a snapshot read from r,
+	// replacing its current state. Keys whose interval has elapsed since the
+	// snapshot was taken are not restored.
+	io.ReaderFrom
+}
+
+var (
+	// ErrSnapshotNotSupported is returned when persisting the state of a
+	// [Sampler] that does not implement [Snapshotter].
+	ErrSnapshotNotSupported = errors.New("sampler does not support snapshots")
+	// ErrInvalidSnapshot is returned when restoring a malformed snapshot.
+	ErrInvalidSnapshot = timed.ErrInvalidSnapshot
+	// ErrUnsupportedSnapshotVersion is returned when restoring a snapshot
+	// encoded with an unsupported version.
+	ErrUnsupportedSnapshotVersion = timed.ErrUnsupportedSnapshotVersion
+)
+
+// WriteSnapshot writes a snapshot of the state of the given sampler to w, if
+// it implements [Snapshotter].
+func WriteSnapshot(s Sampler, w io.Writer) error {
+	snapshotter, ok := s.(Snapshotter)
+	if !ok {
+		return ErrSnapshotNotSupported
+	}
+	_, err := snapshotter.WriteTo(w)
+	return err
+}
+
+// ReadSnapshot restores the state of the given sampler from a snapshot written
+// by [WriteSnapshot], if it implements [Snapshotter]. The sampler should be
+// configured the same way as the one the snapshot was taken from.
+func ReadSnapshot(s Sampler, r io.Reader) error {
+	snapshotter, ok := s.(Snapshotter)
+	if !ok {
+		return ErrSnapshotNotSupported
+	}
+	_, err := snapshotter.ReadFrom(r)
+	return err
+}
+
+// WriteTo writes a snapshot of the keys tracked by this sampler to w.
+func (s *timedSetSampler) WriteTo(w io.Writer) (int64, error) {
+	return (*timed.LRU)(s).WriteTo(w)
+}
+
+// ReadFrom restores the keys tracked by this sampler from a snapshot read from
+// r.
+func (s *timedSetSampler) ReadFrom(r io.Reader) (int64, error) {
+	return (*timed.LRU)(s).ReadFrom(r)
+}
+
+// WriteTo writes the snapshots of the samplers of all the intervals to w, one
+// after the other.
+func (s *overrideSampler) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	for _, sampler := range s.samplers() {
+		n, err := sampler.(Snapshotter).WriteTo(w)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// ReadFrom restores the samplers of all the intervals from the snapshots read
+// from r, in the order they were written by [overrideSampler.WriteTo].
+func (s *overrideSampler) ReadFrom(r io.Reader) (int64, error) {
+	var total int64
+	for _, sampler := range s.samplers() {
+		n, err := sampler.(Snapshotter).ReadFrom(r)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// WriteTo writes a snapshot of the wrapped sampler to w, if it implements
+// [Snapshotter].
+func (s *replicaSampler) WriteTo(w io.Writer) (int64, error) {
+	snapshotter, ok := s.sampler.(Snapshotter)
+	if !ok {
+		return 0, ErrSnapshotNotSupported
+	}
+	return snapshotter.WriteTo(w)
+}
+
+// ReadFrom restores the wrapped sampler from a snapshot read from r, if it
+// implements [Snapshotter].
+func (s *replicaSampler) ReadFrom(r io.Reader) (int64, error) {
+	snapshotter, ok := s.sampler.(Snapshotter)
+	if !ok {
+		return 0, ErrSnapshotNotSupported
+	}
+	return snapshotter.ReadFrom(r)
+}
@@ -0,0 +1,97 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+package apisec
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot(t *testing.T) {
+	var (
+		login   = SamplingKey{Method: http.MethodPost, Route: "/login", StatusCode: http.StatusOK}
+		users   = SamplingKey{Method: http.MethodGet, Route: "/users", StatusCode: http.StatusOK}
+		metrics = SamplingKey{Method: http.MethodGet, Route: "/metrics", StatusCode: http.StatusOK}
+	)
+
+	t.Run("timed", func(t *testing.T) {
+		fakeTime := time.Now().Unix()
+		clock := func() int64 { return fakeTime }
+
+		original := newSampler(30*time.Second, clock)
+		require.True(t, original.DecisionFor(login))
+		fakeTime += 20
+		require.True(t, original.DecisionFor(users))
+
+		var buf bytes.Buffer
+		require.NoError(t, WriteSnapshot(original, &buf))
+
+		fakeTime += 15
+		restored := newSampler(30*time.Second, clock)
+		require.NoError(t, ReadSnapshot(restored, &buf))
+		require.True(t, restored.DecisionFor(login))
+		require.False(t, restored.DecisionFor(users))
+	})
+
+	t.Run("overrides", func(t *testing.T) {
+		fakeTime := time.Now().Unix()
+		clock := func() int64 { return fakeTime }
+		overrides := []IntervalOverride{
+			{Route: "/metrics", Interval: time.Hour},
+			{Route: "/health", Interval: NeverSample},
+		}
+
+		original := newOverrideSampler(30*time.Second, overrides, clock)
+		require.True(t, original.DecisionFor(login))
+		require.True(t, original.DecisionFor(metrics))
+
+		var buf bytes.Buffer
+		require.NoError(t, WriteSnapshot(original, &buf))
+
+		fakeTime += 60
+		restored := newOverrideSampler(30*time.Second, overrides, clock)
+		require.NoError(t, ReadSnapshot(restored, &buf))
+		require.Zero(t, buf.Len())
+		require.True(t, restored.DecisionFor(login))
+		require.False(t, restored.DecisionFor(metrics))
+	})
+
+	t.Run("replica", func(t *testing.T) {
+		fakeTime := time.Now().Unix()
+		clock := func() int64 { return fakeTime }
+		replicaID := ReplicaOf(login, 2)
+
+		original := NewReplicaSampler(replicaID, 2, newSampler(30*time.Second, clock))
+		require.True(t, original.DecisionFor(login))
+
+		var buf bytes.Buffer
+		require.NoError(t, WriteSnapshot(original, &buf))
+
+		restored := NewReplicaSampler(replicaID, 2, newSampler(30*time.Second, clock))
+		require.NoError(t, ReadSnapshot(restored, &buf))
+		require.False(t, restored.DecisionFor(login))
+
+		require.ErrorIs(t, WriteSnapshot(NewReplicaSampler(0, 2, &nullSampler{}), &buf), ErrSnapshotNotSupported)
+	})
+
+	t.Run("not-supported", func(t *testing.T) {
+		proxy := NewProxySampler(1, time.Second)
+		defer CloseSampler(proxy)
+
+		var buf bytes.Buffer
+		require.ErrorIs(t, WriteSnapshot(&nullSampler{}, &buf), ErrSnapshotNotSupported)
+		require.ErrorIs(t, ReadSnapshot(proxy, &buf), ErrSnapshotNotSupported)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		subject := NewSamplerWithInterval(30 * time.Second)
+		require.ErrorIs(t, ReadSnapshot(subject, bytes.NewReader([]byte("not a snapshot"))), ErrInvalidSnapshot)
+	})
+}
@@ -6,6 +6,7 @@
 package appsec
 
 import (
+	"fmt"
 	"os"
 	"regexp"
 	"strconv"
@@ -14,6 +15,7 @@ import (
 	"unicode/utf8"
 
 	"github.com/DataDog/appsec-internal-go/apisec"
+	"github.com/DataDog/appsec-internal-go/internal/env"
 	"github.com/DataDog/appsec-internal-go/log"
 )
 
@@ -27,6 +29,21 @@ const (
 	// EnvAPISecProxySampleRate is the env var used to set the sampling rate of API Security schema extraction for proxies.
 	// The value represents the number of schemas extracted per minute (samples per minute).
 	EnvAPISecProxySampleRate = "DD_API_SECURITY_PROXY_SAMPLE_RATE"
+	// EnvAPISecProxySamplePerEndpoint is the env var used to enable the per-endpoint deduplication of the API Security
+	// proxy sampler, so that each endpoint is sampled at most once per interval within the proxy sampling rate.
+	EnvAPISecProxySamplePerEndpoint = "DD_API_SECURITY_PROXY_SAMPLE_PER_ENDPOINT"
+	// EnvAPISecSamplerCapacity is the env var used to set the number of endpoints tracked by the API Security sampler,
+	// past which the least recently sampled ones are evicted.
+	EnvAPISecSamplerCapacity = "DD_API_SECURITY_SAMPLER_CAPACITY"
+	// EnvAPISecSampleIntervalOverrides is the env var used to override the API Security sampling interval of some
+	// endpoints, as a comma-separated list of `[METHOD ]ROUTE=INTERVAL` overrides (e.g. `POST /login=5s,/health=never`).
+	EnvAPISecSampleIntervalOverrides = "DD_API_SECURITY_SAMPLE_INTERVAL_OVERRIDES"
+	// EnvAPISecReplicaID is the env var used to set the ID of this replica, in [0, EnvAPISecReplicaCount), so that
+	// API Security only samples the endpoints owned by this replica among all the replicas of the service.
+	EnvAPISecReplicaID = "DD_API_SECURITY_REPLICA_ID"
+	// EnvAPISecReplicaCount is the env var used to set the number of replicas of the service, among which API Security
+	// sampling is distributed when greater than 1. It must be the same for all the replicas.
+	EnvAPISecReplicaCount = "DD_API_SECURITY_REPLICA_COUNT"
 	// EnvObfuscatorKey is the env var used to provide the WAF key obfuscation regexp
 	EnvObfuscatorKey = "DD_APPSEC_OBFUSCATION_PARAMETER_KEY_REGEXP"
 	// EnvObfuscatorValue is the env var used to provide the WAF value obfuscation regexp
@@ -86,7 +103,7 @@ type APISecOption func(*APISecConfig)
 // NewAPISecConfig creates and returns a new API Security configuration by reading the env
 func NewAPISecConfig(opts ...APISecOption) APISecConfig {
 	cfg := APISecConfig{
-		Enabled:    boolEnv(EnvAPISecEnabled, true),
+		Enabled:    env.Bool(EnvAPISecEnabled, true),
 		SampleRate: readAPISecuritySampleRate(),
 	}
 	for _, opt := range opts {
@@ -98,15 +115,84 @@ func NewAPISecConfig(opts ...APISecOption) APISecConfig {
 	}
 
 	if cfg.IsProxy {
-		rate := intEnv(EnvAPISecProxySampleRate, DefaultAPISecProxySampleRate)
-		cfg.Sampler = apisec.NewProxySampler(rate, DefaultAPISecProxySampleInterval)
+		rate := env.Int(EnvAPISecProxySampleRate, DefaultAPISecProxySampleRate)
+		if env.Bool(EnvAPISecProxySamplePerEndpoint, false) {
+			cfg.Sampler = apisec.NewKeyedProxySampler(
+				rate,
+				DefaultAPISecProxySampleInterval,
+				env.Duration(envAPISecSampleDelay, "s", DefaultAPISecSampleInterval),
+				apisec.WithCapacity(readAPISecSamplerCapacity()),
+			)
+		} else {
+			cfg.Sampler = apisec.NewProxySampler(rate, DefaultAPISecProxySampleInterval)
+		}
 	} else {
-		cfg.Sampler = apisec.NewSamplerWithInterval(durationEnv(envAPISecSampleDelay, "s", DefaultAPISecSampleInterval))
+		cfg.Sampler = apisec.NewSamplerWithOverrides(
+			env.Duration(envAPISecSampleDelay, "s", DefaultAPISecSampleInterval),
+			readAPISecSampleIntervalOverrides(),
+			apisec.WithCapacity(readAPISecSamplerCapacity()),
+		)
+	}
+
+	if replicaID, replicaCount := readAPISecReplica(); replicaCount > 1 {
+		cfg.Sampler = apisec.NewReplicaSampler(replicaID, replicaCount, cfg.Sampler)
 	}
 
 	return cfg
 }
 
+// Close releases the resources held by the API Security sampler, such as the goroutine refilling the rate limiter of
+// proxy samplers. The sampler must no longer be used afterwards.
+func (c APISecConfig) Close() error {
+	return apisec.CloseSampler(c.Sampler)
+}
+
+// readAPISecReplica reads the ID of this replica and the number of replicas among which API Security sampling is
+// distributed from the env. A single replica is returned when either is not valid.
+func readAPISecReplica() (replicaID int, replicaCount int) {
+	replicaCount = env.Int(EnvAPISecReplicaCount, 1)
+	if replicaCount <= 1 {
+		return 0, 1
+	}
+	value, ok := os.LookupEnv(EnvAPISecReplicaID)
+	if !ok {
+		env.LogUnexpectedValue(EnvAPISecReplicaCount, replicaCount, fmt.Sprintf("%s is not set", EnvAPISecReplicaID), 1)
+		return 0, 1
+	}
+	replicaID, err := strconv.Atoi(value)
+	if err != nil || replicaID < 0 || replicaID >= replicaCount {
+		env.LogUnexpectedValue(EnvAPISecReplicaID, value, fmt.Sprintf("expecting a value in [0, %d)", replicaCount), "sampling all endpoints")
+		return 0, 1
+	}
+	log.Debug("appsec: sampling the API Security endpoints owned by replica %d of %d", replicaID, replicaCount)
+	return replicaID, replicaCount
+}
+
+// readAPISecSamplerCapacity reads the number of endpoints tracked by the API Security sampler from the env.
+func readAPISecSamplerCapacity() int {
+	capacity := env.Int(EnvAPISecSamplerCapacity, apisec.DefaultCapacity)
+	if capacity <= 0 || capacity > apisec.MaxCapacity {
+		env.LogUnexpectedValue(EnvAPISecSamplerCapacity, capacity, fmt.Sprintf("expecting a value in (0, %d]", apisec.MaxCapacity), apisec.DefaultCapacity)
+		return apisec.DefaultCapacity
+	}
+	return capacity
+}
+
+// readAPISecSampleIntervalOverrides reads the API Security sampling interval overrides from the env.
+func readAPISecSampleIntervalOverrides() []apisec.IntervalOverride {
+	value := os.Getenv(EnvAPISecSampleIntervalOverrides)
+	if value == "" {
+		return nil
+	}
+	overrides, err := apisec.ParseIntervalOverrides(value)
+	if err != nil {
+		env.LogUnexpectedValue(EnvAPISecSampleIntervalOverrides, value, err.Error(), "no overrides")
+		return nil
+	}
+	log.Debug("appsec: using the API Security sampling interval overrides %s configured through %s", value, EnvAPISecSampleIntervalOverrides)
+	return overrides
+}
+
 func readAPISecuritySampleRate() float64 {
 	value := os.Getenv(EnvAPISecSampleRate)
 	if value == "" {
@@ -115,7 +201,7 @@ func readAPISecuritySampleRate() float64 {
 
 	rate, err := strconv.ParseFloat(value, 64)
 	if err != nil {
-		logEnvVarParsingError(EnvAPISecSampleRate, value, err, DefaultAPISecSampleRate)
+		env.LogParsingError(EnvAPISecSampleRate, value, err, DefaultAPISecSampleRate)
 		return DefaultAPISecSampleRate
 	}
 	// Clamp the value so that 0.0 <= rate <= 1.0
@@ -145,7 +231,7 @@ func WithProxy() APISecOption {
 // RASPEnabled returns true if RASP functionalities are enabled through the env, or if DD_APPSEC_RASP_ENABLED
 // is not set
 func RASPEnabled() bool {
-	return boolEnv(EnvRASPEnabled, true)
+	return env.Bool(EnvRASPEnabled, true)
 }
 
 // NewObfuscatorConfig creates and returns a new WAF obfuscator configuration by reading the env
@@ -162,7 +248,7 @@ func readObfuscatorConfigRegexp(name, defaultValue string) string {
 		return defaultValue
 	}
 	if _, err := regexp.Compile(val); err != nil {
-		logUnexpectedEnvVarValue(name, val, "could not compile the configured obfuscator regular expression", defaultValue)
+		env.LogUnexpectedValue(name, val, "could not compile the configured obfuscator regular expression", defaultValue)
 		return defaultValue
 	}
 	log.Debug("appsec: starting with the configured obfuscator regular expression %s", name)
@@ -187,11 +273,11 @@ func WAFTimeoutFromEnv() (timeout time.Duration) {
 
 	parsed, err := time.ParseDuration(value)
 	if err != nil {
-		logEnvVarParsingError(EnvWAFTimeout, value, err, timeout)
+		env.LogParsingError(EnvWAFTimeout, value, err, timeout)
 		return
 	}
 	if parsed <= 0 {
-		logUnexpectedEnvVarValue(EnvWAFTimeout, parsed, "expecting a strictly positive duration", timeout)
+		env.LogUnexpectedValue(EnvWAFTimeout, parsed, "expecting a strictly positive duration", timeout)
 		return
 	}
 	return parsed
@@ -207,11 +293,11 @@ func RateLimitFromEnv() (rate uint) {
 	}
 	parsed, err := strconv.ParseUint(value, 10, 0)
 	if err != nil {
-		logEnvVarParsingError(EnvTraceRateLimit, value, err, rate)
+		env.LogParsingError(EnvTraceRateLimit, value, err, rate)
 		return
 	}
 	if parsed == 0 {
-		logUnexpectedEnvVarValue(EnvTraceRateLimit, parsed, "expecting a value strictly greater than 0", rate)
+		env.LogUnexpectedValue(EnvTraceRateLimit, parsed, "expecting a value strictly greater than 0", rate)
 		return
 	}
 	return uint(parsed)
@@ -235,50 +321,3 @@ func RulesFromEnv() ([]byte, error) {
 	log.Debug("appsec: using the security rules from file %s", filepath)
 	return buf, nil
 }
-
-func logEnvVarParsingError(name, value string, err error, defaultValue any) {
-	log.Debug("appsec: could not parse the env var %s=%s as a duration: %v. Using default value %v.", name, value, err, defaultValue)
-}
-
-func logUnexpectedEnvVarValue(name string, value any, reason string, defaultValue any) {
-	log.Debug("appsec: unexpected configuration value of %s=%v: %s. Using default value %v.", name, value, reason, defaultValue)
-}
-
-func boolEnv(key string, def bool) bool {
-	strVal, ok := os.LookupEnv(key)
-	if !ok {
-		return def
-	}
-	v, err := strconv.ParseBool(strVal)
-	if err != nil {
-		logEnvVarParsingError(key, strVal, err, def)
-		return def
-	}
-	return v
-}
-
-func durationEnv(key string, unit string, def time.Duration) time.Duration {
-	strVal, ok := os.LookupEnv(key)
-	if !ok {
-		return def
-	}
-	val, err := time.ParseDuration(strVal + unit)
-	if err != nil {
-		logEnvVarParsingError(key, strVal, err, def)
-		return def
-	}
-	return val
-}
-
-func intEnv(key string, def int) int {
-	strVal, ok := os.LookupEnv(key)
-	if !ok {
-		return def
-	}
-	val, err := strconv.Atoi(strVal)
-	if err != nil {
-		logEnvVarParsingError(key, strVal, err, def)
-		return def
-	}
-	return val
-}
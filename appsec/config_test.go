@@ -6,11 +6,15 @@
 package appsec
 
 import (
+	"bytes"
 	"os"
+	"strconv"
 	"testing"
 	"time"
 
+	"github.com/DataDog/appsec-internal-go/apisec"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
 )
 
 func TestAPISecConfig(t *testing.T) {
@@ -57,6 +61,162 @@ func TestAPISecConfig(t *testing.T) {
 	}
 }
 
+func TestAPISecSamplerCapacity(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		env      string
+		expected int
+	}{
+		{name: "default", expected: apisec.DefaultCapacity},
+		{name: "parsable", env: "65536", expected: 65536},
+		{name: "not-parsable", env: "lots", expected: apisec.DefaultCapacity},
+		{name: "zero", env: "0", expected: apisec.DefaultCapacity},
+		{name: "negative", env: "-1", expected: apisec.DefaultCapacity},
+		{name: "too-large", env: "1000000000", expected: apisec.DefaultCapacity},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.env != "" {
+				t.Setenv(EnvAPISecSamplerCapacity, tc.env)
+			}
+			require.Equal(t, tc.expected, readAPISecSamplerCapacity())
+		})
+	}
+}
+
+func TestAPISecSampleIntervalOverrides(t *testing.T) {
+	t.Run("unset", func(t *testing.T) {
+		require.Nil(t, readAPISecSampleIntervalOverrides())
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		t.Setenv(EnvAPISecSampleIntervalOverrides, "POST /login=5s, /health=never")
+		require.Equal(t, []apisec.IntervalOverride{
+			{Method: "POST", Route: "/login", Interval: 5 * time.Second},
+			{Route: "/health", Interval: apisec.NeverSample},
+		}, readAPISecSampleIntervalOverrides())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Setenv(EnvAPISecSampleIntervalOverrides, "/login")
+		require.Nil(t, readAPISecSampleIntervalOverrides())
+	})
+
+	for _, value := range []string{"/login=2000000h", "/login=1500h500ms"} {
+		t.Run("too-long", func(t *testing.T) {
+			t.Setenv(EnvAPISecSampleIntervalOverrides, value)
+			require.Nil(t, readAPISecSampleIntervalOverrides())
+			require.NotPanics(t, func() {
+				cfg := NewAPISecConfig()
+				defer cfg.Close()
+			})
+		})
+	}
+}
+
+func TestAPISecProxySamplePerEndpoint(t *testing.T) {
+	key := apisec.SamplingKey{Method: "GET", Route: "/users", StatusCode: 200}
+
+	t.Run("disabled", func(t *testing.T) {
+		cfg := NewAPISecConfig(WithProxy())
+		defer cfg.Close()
+		require.True(t, cfg.Sampler.DecisionFor(key))
+		require.True(t, cfg.Sampler.DecisionFor(key))
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		t.Setenv(EnvAPISecProxySamplePerEndpoint, "true")
+		cfg := NewAPISecConfig(WithProxy())
+		defer cfg.Close()
+		require.True(t, cfg.Sampler.DecisionFor(key))
+		require.False(t, cfg.Sampler.DecisionFor(key))
+	})
+}
+
+func TestAPISecReplica(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		replicaID     string
+		replicaCount  string
+		expectedID    int
+		expectedCount int
+	}{
+		{name: "unset", expectedCount: 1},
+		{name: "single", replicaID: "0", replicaCount: "1", expectedCount: 1},
+		{name: "valid", replicaID: "2", replicaCount: "3", expectedID: 2, expectedCount: 3},
+		{name: "missing-id", replicaCount: "3", expectedCount: 1},
+		{name: "id-too-large", replicaID: "3", replicaCount: "3", expectedCount: 1},
+		{name: "negative-id", replicaID: "-1", replicaCount: "3", expectedCount: 1},
+		{name: "not-parsable-id", replicaID: "first", replicaCount: "3", expectedCount: 1},
+		{name: "not-parsable-count", replicaID: "1", replicaCount: "three", expectedCount: 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.replicaID != "" {
+				t.Setenv(EnvAPISecReplicaID, tc.replicaID)
+			}
+			if tc.replicaCount != "" {
+				t.Setenv(EnvAPISecReplicaCount, tc.replicaCount)
+			}
+			replicaID, replicaCount := readAPISecReplica()
+			require.Equal(t, tc.expectedID, replicaID)
+			require.Equal(t, tc.expectedCount, replicaCount)
+		})
+	}
+
+	t.Run("sampler", func(t *testing.T) {
+		t.Setenv(EnvAPISecReplicaCount, "2")
+		keys := []apisec.SamplingKey{
+			{Method: "GET", Route: "/users/{id}", StatusCode: 200},
+			{Method: "POST", Route: "/login", StatusCode: 302},
+		}
+		require.NotEqual(t, apisec.ReplicaOf(keys[0], 2), apisec.ReplicaOf(keys[1], 2))
+		for replicaID := range 2 {
+			t.Setenv(EnvAPISecReplicaID, strconv.Itoa(replicaID))
+			cfg := NewAPISecConfig()
+			for _, key := range keys {
+				require.Equal(t, apisec.ReplicaOf(key, 2) == replicaID, cfg.Sampler.DecisionFor(key))
+			}
+		}
+	})
+
+	t.Run("stats-and-snapshot", func(t *testing.T) {
+		key := apisec.SamplingKey{Method: "GET", Route: "/users/{id}", StatusCode: 200}
+		t.Setenv(EnvAPISecReplicaCount, "2")
+		t.Setenv(EnvAPISecReplicaID, strconv.Itoa(apisec.ReplicaOf(key, 2)))
+
+		cfg := NewAPISecConfig()
+		require.True(t, cfg.Sampler.DecisionFor(key))
+		stats, ok := apisec.StatsOf(cfg.Sampler)
+		require.True(t, ok)
+		require.Equal(t, apisec.SamplerStats{Keeps: 1, LiveEntries: 1}, stats)
+
+		var buf bytes.Buffer
+		require.NoError(t, apisec.WriteSnapshot(cfg.Sampler, &buf))
+		restored := NewAPISecConfig()
+		require.NoError(t, apisec.ReadSnapshot(restored.Sampler, &buf))
+		require.False(t, restored.Sampler.DecisionFor(key))
+	})
+}
+
+func TestAPISecConfigClose(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	for name, opts := range map[string][]APISecOption{
+		"default": nil,
+		"proxy":   {WithProxy()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := NewAPISecConfig(opts...)
+			require.NoError(t, cfg.Close())
+		})
+	}
+
+	t.Run("per-endpoint", func(t *testing.T) {
+		t.Setenv(EnvAPISecProxySamplePerEndpoint, "true")
+		cfg := NewAPISecConfig(WithProxy())
+		require.NoError(t, cfg.Close())
+	})
+}
+
 func TestObfuscatorConfig(t *testing.T) {
 	defaultConfig := ObfuscatorConfig{
 		KeyRegex:   DefaultObfuscatorKeyRegex,
@@ -238,37 +398,3 @@ func TestRASPEnablement(t *testing.T) {
 		require.True(t, RASPEnabled())
 	})
 }
-
-func TestDurationEnv(t *testing.T) {
-	const varName = "DD_TEST_VARIABLE_DURATION"
-
-	type testCase struct {
-		EnvVal   string
-		EnvUnit  string
-		Expected time.Duration
-	}
-	testCases := map[string]testCase{
-		"blank": {
-			EnvVal:   "",
-			EnvUnit:  "s",
-			Expected: 1337,
-		},
-		"1m": {
-			EnvVal:   "1",
-			EnvUnit:  "m",
-			Expected: time.Minute,
-		},
-		"invalid": {
-			EnvVal:   "invalid",
-			EnvUnit:  "s",
-			Expected: 1337,
-		},
-	}
-
-	for name, tc := range testCases {
-		t.Run(name, func(t *testing.T) {
-			t.Setenv(varName, tc.EnvVal)
-			require.Equal(t, tc.Expected, durationEnv(varName, tc.EnvUnit, 1337))
-		})
-	}
-}
@@ -1,9 +1,10 @@
 package httpsec
 
 import (
-	"net"
+	"maps"
 	"net/textproto"
 	"strings"
+	"sync/atomic"
 
 	"github.com/DataDog/appsec-internal-go/netip"
 )
@@ -14,113 +15,326 @@ const (
 	// ClientIPTag is the tag name used for the client IP deduced from the HTTP
 	// request headers with ClientIP().
 	ClientIPTag = "http.client_ip"
+	// ClientIPConflictTag is the tag name used to report the comma-separated
+	// list of monitored headers carrying conflicting client IP addresses, as
+	// detected by ClientIPConflict().
+	ClientIPConflictTag = "_dd.appsec.client_ip_conflict"
 )
 
+// defaultClientIPHeaders is the list of headers monitored by default to find
+// the client IP address, in order of precedence.
+var defaultClientIPHeaders = [...]string{
+	"x-forwarded-for",
+	"x-real-ip",
+	"true-client-ip",
+	"x-client-ip",
+	"forwarded",
+	"x-forwarded",
+	"forwarded-for",
+	"x-cluster-client-ip",
+	"fastly-client-ip",
+	"cf-connecting-ip",
+	"cf-connecting-ipv6",
+}
+
+// DefaultClientIPHeaders returns a copy of the default list of headers to
+// monitor with ClientIP(), in order of precedence.
+func DefaultClientIPHeaders() []string {
+	return append([]string(nil), defaultClientIPHeaders[:]...)
+}
+
+// MonitoredClientIPHeaders returns the list of headers to monitor with
+// ClientIP(). This is the single header configured with the
+// `DD_TRACE_CLIENT_IP_HEADER` env var when set, or the default list returned by
+// DefaultClientIPHeaders() otherwise.
+func MonitoredClientIPHeaders() []string {
+	if header := clientIPHeaderFromEnv(); header != "" {
+		return []string{header}
+	}
+	return DefaultClientIPHeaders()
+}
+
 // ClientIPTags returns the resulting Datadog span tags `http.client_ip`
 // containing the client IP and `network.client.ip` containing the remote IP.
 // The tags are present only if a valid ip address has been returned by
-// ClientIP().
+// ClientIP(). The IP addresses are anonymized by the anonymizer configured with
+// the `DD_TRACE_CLIENT_IP_ANONYMIZATION` env var (see
+// ClientIPAnonymizerFromEnv()), which is read once, and reported as-is by
+// default.
 func ClientIPTags(remoteIP, clientIP netip.Addr) (tags map[string]string) {
-	remoteIPValid := remoteIP.IsValid()
-	clientIPValid := clientIP.IsValid()
-	if !remoteIPValid && !clientIPValid {
-		return nil
-	}
-
-	tags = make(map[string]string, 2)
-	if remoteIPValid {
-		tags[RemoteIPTag] = remoteIP.String()
-	}
-	if clientIPValid {
-		tags[ClientIPTag] = clientIP.String()
-	}
-	return tags
+	return AnonymizedClientIPTags(remoteIP, clientIP, defaultClientIPAnonymizer())
 }
 
 // ClientIP returns the first public IP address found in the given headers. If
 // none is present, it returns the first valid IP address present, possibly
 // being a local IP address. The remote address, when valid, is used as fallback
-// when no IP address has been found at all.
+// when no IP address has been found at all. IPv4 addresses represented as
+// IPv6 addresses (e.g. `::ffff:203.0.113.5` or `64:ff9b::cb00:7105`) are
+// returned as IPv4 addresses.
 func ClientIP(hdrs map[string][]string, hasCanonicalHeaders bool, remoteAddr string, monitoredHeaders []string) (remoteIP, clientIP netip.Addr) {
+	return clientIPFromRemoteIP(hdrs, hasCanonicalHeaders, parseIP(remoteAddr), monitoredHeaders)
+}
+
+// ClientIPInfo is the result of the client IP address resolution performed by
+// ResolveClientIP(), along with where the client IP address was found.
+type ClientIPInfo struct {
+	// RemoteIP is the remote IP address of the request, if valid.
+	RemoteIP netip.Addr
+	// ClientIP is the client IP address, as returned by ClientIP().
+	ClientIP netip.Addr
+	// Header is the name of the monitored header the client IP address was
+	// found in, as listed in the monitored headers. It is empty when the client
+	// IP address is the remote IP address.
+	Header string
+	// HeaderValues are the values of the header the client IP address was found
+	// in, holding the forwarded chain of addresses the request went through.
+	HeaderValues []string
+}
+
+// ResolveClientIP returns the remote IP address and the client IP address as
+// ClientIP() does, along with the monitored header the client IP address was
+// found in and its values.
+func ResolveClientIP(hdrs map[string][]string, hasCanonicalHeaders bool, remoteAddr string, monitoredHeaders []string) ClientIPInfo {
+	return resolveClientIP(hdrs, hasCanonicalHeaders, parseIP(remoteAddr), monitoredHeaders)
+}
+
+// clientIPFromRemoteIP implements ClientIP() given the already parsed remote
+// IP address, which is invalid when the remote address could not be parsed.
+func clientIPFromRemoteIP(hdrs map[string][]string, hasCanonicalHeaders bool, parsedRemoteIP netip.Addr, monitoredHeaders []string) (remoteIP, clientIP netip.Addr) {
+	info := resolveClientIP(hdrs, hasCanonicalHeaders, parsedRemoteIP, monitoredHeaders)
+	return info.RemoteIP, info.ClientIP
+}
+
+// resolveClientIP implements ResolveClientIP() given the already parsed remote
+// IP address, which is invalid when the remote address could not be parsed.
+func resolveClientIP(hdrs map[string][]string, hasCanonicalHeaders bool, parsedRemoteIP netip.Addr, monitoredHeaders []string) (info ClientIPInfo) {
 	// Walk IP-related headers
-	var foundIP netip.Addr
-headersLoop:
+	var (
+		foundIP           netip.Addr
+		foundHeader       string
+		foundHeaderValues []string
+	)
 	for _, headerName := range monitoredHeaders {
-		if hasCanonicalHeaders {
-			headerName = textproto.CanonicalMIMEHeaderKey(headerName)
-		}
-
-		headerValues, exists := hdrs[headerName]
+		headerValues, exists := lookupHeader(hdrs, hasCanonicalHeaders, headerName)
 		if !exists {
 			continue // this monitored header is not present
 		}
 
-		// Assuming a list of comma-separated IP addresses, split them and build
-		// the list of values to try to parse as IP addresses
-		var ips []string
-		for _, ip := range headerValues {
-			ips = append(ips, strings.Split(ip, ",")...)
+		ip, global := headerClientIP(headerName, headerValues)
+		// Replace foundIP if still not valid in order to keep the oldest
+		if !foundIP.IsValid() || global {
+			foundIP, foundHeader, foundHeaderValues = ip, headerName, headerValues
 		}
-
-		// Look for the first valid or global IP address in the comma-separated list
-		for _, ipstr := range ips {
-			ip := parseIP(strings.TrimSpace(ipstr))
-			if !ip.IsValid() {
-				continue
-			}
-			// Replace foundIP if still not valid in order to keep the oldest
-			if !foundIP.IsValid() {
-				foundIP = ip
-			}
-			if isGlobal(ip) {
-				foundIP = ip
-				break headersLoop
-			}
+		if global {
+			break
 		}
 	}
 
 	// Decide which IP address is the client one by starting with the remote IP
-	if ip := parseIP(remoteAddr); ip.IsValid() {
-		remoteIP = ip
-		clientIP = ip
+	if parsedRemoteIP.IsValid() {
+		info.RemoteIP = parsedRemoteIP
+		info.ClientIP = parsedRemoteIP
 	}
 
 	// The IP address found in the headers supersedes a private remote IP address.
-	if foundIP.IsValid() && !isGlobal(remoteIP) || isGlobal(foundIP) {
-		clientIP = foundIP
+	if foundIP.IsValid() && !netip.IsGlobal(info.RemoteIP) || netip.IsGlobal(foundIP) {
+		info.ClientIP = foundIP
+		info.Header = foundHeader
+		info.HeaderValues = foundHeaderValues
 	}
 
-	return remoteIP, clientIP
+	return info
 }
 
-func parseIP(s string) netip.Addr {
-	if ip, err := netip.ParseAddr(s); err == nil {
-		return ip
+// ClientIPConflict reports whether several of the monitored headers carry
+// different public client IP addresses, which is a common sign of client IP
+// spoofing (e.g. a forged `X-Forwarded-For` header sent along with the
+// `True-Client-IP` header set by a CDN). When a conflict is detected, the names
+// of the monitored headers carrying a public IP address are returned in the
+// order they were monitored. Private IP addresses are ignored, as they usually
+// are the addresses of internal proxies rather than spoofing attempts.
+func ClientIPConflict(hdrs map[string][]string, hasCanonicalHeaders bool, monitoredHeaders []string) (conflict bool, headers []string) {
+	var firstIP netip.Addr
+	for _, headerName := range monitoredHeaders {
+		ip, global := monitoredHeaderClientIP(hdrs, hasCanonicalHeaders, headerName)
+		if !global {
+			continue
+		}
+		if !firstIP.IsValid() {
+			firstIP = ip
+		} else if ip != firstIP {
+			conflict = true
+			break
+		}
+	}
+
+	if !conflict {
+		return false, nil
+	}
+
+	// Conflicts are expected to be rare, so we only pay for building the list of
+	// headers when there is one.
+	for _, headerName := range monitoredHeaders {
+		if _, global := monitoredHeaderClientIP(hdrs, hasCanonicalHeaders, headerName); global {
+			headers = append(headers, headerName)
+		}
+	}
+	return true, headers
+}
+
+// monitoredHeaderClientIP returns the client IP address found in the given
+// monitored header as headerClientIP() does, or an invalid address if the
+// header is not present.
+func monitoredHeaderClientIP(hdrs map[string][]string, hasCanonicalHeaders bool, headerName string) (ip netip.Addr, global bool) {
+	headerValues, exists := lookupHeader(hdrs, hasCanonicalHeaders, headerName)
+	if !exists {
+		return netip.Addr{}, false
+	}
+	return headerClientIP(headerName, headerValues)
+}
+
+// ClientIPConflictTags returns the resulting Datadog span tag
+// `_dd.appsec.client_ip_conflict` containing the comma-separated list of
+// headers returned by ClientIPConflict(). The tag is present only if a
+// conflict has been detected.
+func ClientIPConflictTags(conflict bool, headers []string) map[string]string {
+	if !conflict || len(headers) == 0 {
+		return nil
+	}
+	return map[string]string{ClientIPConflictTag: strings.Join(headers, ",")}
+}
+
+// lookupHeader returns the values of the given header, canonicalizing its name
+// first if the headers map uses canonical MIME header keys.
+func lookupHeader(hdrs map[string][]string, hasCanonicalHeaders bool, headerName string) ([]string, bool) {
+	if hasCanonicalHeaders {
+		headerName = canonicalHeaderKey(headerName)
+	}
+	headerValues, exists := hdrs[headerName]
+	return headerValues, exists
+}
+
+// maxCanonicalHeaderKeys is the maximum number of header names whose canonical
+// MIME header key is cached by canonicalHeaderKey().
+const maxCanonicalHeaderKeys = 64
+
+// canonicalHeaderKeys caches the canonical MIME header keys of the monitored
+// headers, as canonicalizing a lowercase header name allocates a new string.
+// The map is never modified in place, but replaced by an updated copy, so that
+// it can be read without locking.
+var canonicalHeaderKeys atomic.Pointer[map[string]string]
+
+func init() {
+	keys := make(map[string]string, len(defaultClientIPHeaders))
+	for _, name := range defaultClientIPHeaders {
+		keys[name] = textproto.CanonicalMIMEHeaderKey(name)
+	}
+	canonicalHeaderKeys.Store(&keys)
+}
+
+// canonicalHeaderKey returns the canonical MIME header key of the given header
+// name, without allocating once it has been cached.
+func canonicalHeaderKey(headerName string) string {
+	keys := canonicalHeaderKeys.Load()
+	if key, found := (*keys)[headerName]; found {
+		return key
 	}
-	if h, _, err := net.SplitHostPort(s); err == nil {
-		if ip, err := netip.ParseAddr(h); err == nil {
-			return ip
+
+	key := textproto.CanonicalMIMEHeaderKey(headerName)
+	if len(*keys) < maxCanonicalHeaderKeys {
+		// Concurrent updates may be lost, in which case the key will simply be
+		// cached by a later call.
+		newKeys := maps.Clone(*keys)
+		newKeys[headerName] = key
+		canonicalHeaderKeys.CompareAndSwap(keys, &newKeys)
+	}
+	return key
+}
+
+// headerClientIP returns the first global IP address found in the values of the
+// given header, or the first valid IP address if none is global. The header
+// values are expected to be lists of comma-separated IP addresses, which are
+// scanned in place without allocating.
+func headerClientIP(headerName string, headerValues []string) (ip netip.Addr, global bool) {
+	for _, value := range headerValues {
+		// Look for the first valid or global IP address in the comma-separated list
+		for value != "" {
+			var elem string
+			elem, value, _ = strings.Cut(value, ",")
+			parsed := headerElementIP(headerName, elem)
+			if !parsed.IsValid() {
+				continue
+			}
+			// Replace ip if still not valid in order to keep the oldest
+			if !ip.IsValid() {
+				ip = parsed
+			}
+			if netip.IsGlobal(parsed) {
+				return parsed, true
+			}
 		}
 	}
-	return netip.Addr{}
+	return ip, false
 }
 
-var ipv6SpecialNetworks = [...]netip.Prefix{
-	netip.MustParsePrefix("fec0::/10"), // site local
+// headerElementIP parses the IP address of the given element of the
+// comma-separated list held by the given header. The node identifier of RFC
+// 7239 elements (e.g. `for=192.0.2.60`) is only extracted from the `Forwarded`
+// header, so that such values are not accepted from other headers.
+func headerElementIP(headerName string, elem string) netip.Addr {
+	elem = strings.TrimSpace(elem)
+	if strings.EqualFold(headerName, "forwarded") {
+		elem = forwardedFor(elem)
+	}
+	return parseIP(elem)
 }
 
-func isGlobal(ip netip.Addr) bool {
-	// IsPrivate also checks for ipv6 ULA.
-	// We care to check for these addresses are not considered public, hence not global.
-	// See https://www.rfc-editor.org/rfc/rfc4193.txt for more details.
-	isGlobal := ip.IsValid() && !ip.IsPrivate() && !ip.IsLoopback() && !ip.IsLinkLocalUnicast()
-	if !isGlobal || !ip.Is6() {
-		return isGlobal
+// forwardedFor returns the node identifier of the `for` parameter of the given
+// RFC 7239 `Forwarded` header element (e.g. `for=192.0.2.60;proto=http`). The
+// element is returned as-is when it has no parameters.
+func forwardedFor(elem string) string {
+	if strings.IndexByte(elem, '=') < 0 {
+		return elem
 	}
-	for _, n := range ipv6SpecialNetworks {
-		if n.Contains(ip) {
-			return false
+	for elem != "" {
+		var pair string
+		pair, elem, _ = strings.Cut(elem, ";")
+		name, value, found := strings.Cut(pair, "=")
+		if !found || !strings.EqualFold(strings.TrimSpace(name), "for") {
+			continue
+		}
+		// Quoted IPv6 node identifiers are enclosed in brackets, with an optional port
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+		if len(value) > 1 && value[0] == '[' && value[len(value)-1] == ']' {
+			value = value[1 : len(value)-1]
 		}
+		return value
+	}
+	return ""
+}
+
+// parseIP parses the given IP address, with an optional port. IPv4-mapped and
+// IPv4/IPv6 translation IPv6 addresses are normalized into the IPv4 address
+// they represent, so that they are classified and reported as IPv4 addresses.
+// The port is stripped before parsing, as failing to parse an address
+// allocates an error.
+func parseIP(s string) netip.Addr {
+	host := s
+	if len(s) > 0 && s[0] == '[' {
+		// [ipv6] or [ipv6]:port
+		end := strings.IndexByte(s, ']')
+		if end < 0 || end+1 < len(s) && s[end+1] != ':' {
+			return netip.Addr{}
+		}
+		host = s[1:end]
+	} else if i := strings.IndexByte(s, ':'); i >= 0 && i == strings.LastIndexByte(s, ':') {
+		// ipv4:port, as IPv6 addresses contain several colons
+		host = s[:i]
+	}
+
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
 	}
-	return isGlobal
+	return netip.Normalize(ip)
 }
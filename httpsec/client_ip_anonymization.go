@@ -0,0 +1,157 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+package httpsec
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+
+	"github.com/DataDog/appsec-internal-go/log"
+	"github.com/DataDog/appsec-internal-go/netip"
+)
+
+type (
+	// ClientIPAnonymization is the way IP addresses are anonymized in the span
+	// tags. The IP addresses returned by ClientIP() are never anonymized, so
+	// that they can still be used for blocking decisions.
+	ClientIPAnonymization uint8
+
+	// ClientIPAnonymizer anonymizes the IP addresses reported in the span tags.
+	// Its zero value reports IP addresses as-is.
+	ClientIPAnonymizer struct {
+		// Mode is the anonymization mode.
+		Mode ClientIPAnonymization
+		// Key is the secret key of the ClientIPAnonymizationHMAC mode.
+		Key []byte
+	}
+)
+
+const (
+	// ClientIPAnonymizationNone reports IP addresses as-is.
+	ClientIPAnonymizationNone ClientIPAnonymization = iota
+	// ClientIPAnonymizationTruncate reports the /24 network of IPv4 addresses,
+	// and the /48 network of IPv6 addresses (e.g. `203.0.113.0` for
+	// `203.0.113.5`).
+	ClientIPAnonymizationTruncate
+	// ClientIPAnonymizationHMAC reports the hex-encoded HMAC-SHA256 of IP
+	// addresses, keyed with a secret key, truncated to 128 bits. The same IP
+	// address always gets the same pseudonym as long as the key is unchanged.
+	ClientIPAnonymizationHMAC
+	// ClientIPAnonymizationOmit does not report IP addresses at all.
+	ClientIPAnonymizationOmit
+)
+
+const (
+	// truncatedIPv4Bits is the length of the networks IPv4 addresses are
+	// truncated to.
+	truncatedIPv4Bits = 24
+	// truncatedIPv6Bits is the length of the networks IPv6 addresses are
+	// truncated to.
+	truncatedIPv6Bits = 48
+	// hmacPseudonymSize is the number of bytes of the HMAC kept in pseudonyms.
+	hmacPseudonymSize = 16
+)
+
+// defaultClientIPAnonymizer returns the anonymizer used by ClientIPTags(),
+// configured through the env the first time it is called.
+var defaultClientIPAnonymizer = sync.OnceValue(ClientIPAnonymizerFromEnv)
+
+// ParseClientIPAnonymization returns the anonymization mode with the given
+// name, either `none`, `truncate`, `hmac` or `omit`.
+func ParseClientIPAnonymization(name string) (ClientIPAnonymization, bool) {
+	switch name {
+	case "none":
+		return ClientIPAnonymizationNone, true
+	case "truncate":
+		return ClientIPAnonymizationTruncate, true
+	case "hmac":
+		return ClientIPAnonymizationHMAC, true
+	case "omit":
+		return ClientIPAnonymizationOmit, true
+	default:
+		return ClientIPAnonymizationNone, false
+	}
+}
+
+// ClientIPAnonymizerFromEnv returns the IP address anonymizer configured with
+// the `DD_TRACE_CLIENT_IP_ANONYMIZATION` and
+// `DD_TRACE_CLIENT_IP_ANONYMIZATION_KEY` env vars. As anonymization is
+// usually a compliance requirement, IP addresses are omitted when the mode is
+// unknown, or when the `hmac` mode is selected without a key. The returned
+// anonymizer is meant to be passed to AnonymizedClientIPTags() or
+// NetworkTags(), and is the one used by ClientIPTags().
+func ClientIPAnonymizerFromEnv() ClientIPAnonymizer {
+	name, key := clientIPAnonymizationFromEnv()
+	if name == "" {
+		return ClientIPAnonymizer{}
+	}
+	mode, found := ParseClientIPAnonymization(name)
+	if !found {
+		log.Warn("httpsec: unknown client IP anonymization mode %s configured through %s, IP addresses will be omitted", name, EnvClientIPAnonymization)
+		return ClientIPAnonymizer{Mode: ClientIPAnonymizationOmit}
+	}
+	if mode == ClientIPAnonymizationHMAC && len(key) == 0 {
+		log.Warn("httpsec: no key configured through %s for the hmac client IP anonymization mode, IP addresses will be omitted", EnvClientIPAnonymizationKey)
+		return ClientIPAnonymizer{Mode: ClientIPAnonymizationOmit}
+	}
+	return ClientIPAnonymizer{Mode: mode, Key: key}
+}
+
+// Anonymize returns the anonymized representation of the given IP address to
+// report in the span tags. It returns false if the IP address should not be
+// reported.
+func (a ClientIPAnonymizer) Anonymize(ip netip.Addr) (string, bool) {
+	if !ip.IsValid() {
+		return "", false
+	}
+
+	switch a.Mode {
+	case ClientIPAnonymizationNone:
+		return ip.String(), true
+	case ClientIPAnonymizationTruncate:
+		bits := truncatedIPv6Bits
+		if ip.Is4() {
+			bits = truncatedIPv4Bits
+		}
+		prefix, err := ip.WithZone("").Prefix(bits)
+		if err != nil {
+			return "", false
+		}
+		return prefix.Addr().String(), true
+	case ClientIPAnonymizationHMAC:
+		if len(a.Key) == 0 {
+			return "", false
+		}
+		mac := hmac.New(sha256.New, a.Key)
+		addr := ip.WithZone("").As16()
+		mac.Write(addr[:])
+		return hex.EncodeToString(mac.Sum(nil)[:hmacPseudonymSize]), true
+	default:
+		return "", false
+	}
+}
+
+// AnonymizedClientIPTags returns the span tags returned by ClientIPTags(),
+// with their IP addresses anonymized by the given anonymizer rather than the
+// one configured through the env.
+func AnonymizedClientIPTags(remoteIP, clientIP netip.Addr, anonymizer ClientIPAnonymizer) (tags map[string]string) {
+	remoteIPValue, remoteIPValid := anonymizer.Anonymize(remoteIP)
+	clientIPValue, clientIPValid := anonymizer.Anonymize(clientIP)
+	if !remoteIPValid && !clientIPValid {
+		return nil
+	}
+
+	tags = make(map[string]string, 2)
+	if remoteIPValid {
+		tags[RemoteIPTag] = remoteIPValue
+	}
+	if clientIPValid {
+		tags[ClientIPTag] = clientIPValue
+	}
+	return tags
+}
@@ -0,0 +1,176 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+package httpsec
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/DataDog/appsec-internal-go/netip"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientIPAnonymizer(t *testing.T) {
+	var (
+		ipv4 = netip.MustParseAddr("93.184.216.34")
+		ipv6 = netip.MustParseAddr("2a00:1450:4007:80e::200e")
+	)
+
+	t.Run("none", func(t *testing.T) {
+		var anonymizer ClientIPAnonymizer
+		value, ok := anonymizer.Anonymize(ipv4)
+		require.True(t, ok)
+		require.Equal(t, "93.184.216.34", value)
+		value, ok = anonymizer.Anonymize(ipv6)
+		require.True(t, ok)
+		require.Equal(t, "2a00:1450:4007:80e::200e", value)
+	})
+
+	t.Run("truncate", func(t *testing.T) {
+		anonymizer := ClientIPAnonymizer{Mode: ClientIPAnonymizationTruncate}
+		value, ok := anonymizer.Anonymize(ipv4)
+		require.True(t, ok)
+		require.Equal(t, "93.184.216.0", value)
+		value, ok = anonymizer.Anonymize(ipv6)
+		require.True(t, ok)
+		require.Equal(t, "2a00:1450:4007::", value)
+		value, ok = anonymizer.Anonymize(netip.MustParseAddr("fe80::1:2:3:4%eth0"))
+		require.True(t, ok)
+		require.Equal(t, "fe80::", value)
+	})
+
+	t.Run("hmac", func(t *testing.T) {
+		anonymizer := ClientIPAnonymizer{Mode: ClientIPAnonymizationHMAC, Key: []byte("secret")}
+		value, ok := anonymizer.Anonymize(ipv4)
+		require.True(t, ok)
+		require.Len(t, value, 2*hmacPseudonymSize)
+		require.NotContains(t, value, "93.184")
+
+		// Pseudonyms are stable for a given key
+		again, _ := anonymizer.Anonymize(netip.MustParseAddr("93.184.216.34"))
+		require.Equal(t, value, again)
+
+		// Pseudonyms are different across addresses and keys
+		other, _ := anonymizer.Anonymize(netip.MustParseAddr("93.184.216.35"))
+		require.NotEqual(t, value, other)
+		otherKey, _ := ClientIPAnonymizer{Mode: ClientIPAnonymizationHMAC, Key: []byte("other")}.Anonymize(ipv4)
+		require.NotEqual(t, value, otherKey)
+
+		_, ok = ClientIPAnonymizer{Mode: ClientIPAnonymizationHMAC}.Anonymize(ipv4)
+		require.False(t, ok)
+	})
+
+	t.Run("omit", func(t *testing.T) {
+		anonymizer := ClientIPAnonymizer{Mode: ClientIPAnonymizationOmit}
+		_, ok := anonymizer.Anonymize(ipv4)
+		require.False(t, ok)
+		require.Nil(t, AnonymizedClientIPTags(ipv4, ipv6, anonymizer))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, ok := ClientIPAnonymizer{}.Anonymize(netip.Addr{})
+		require.False(t, ok)
+	})
+
+	t.Run("tags", func(t *testing.T) {
+		anonymizer := ClientIPAnonymizer{Mode: ClientIPAnonymizationTruncate}
+		require.Equal(t, map[string]string{
+			RemoteIPTag: "10.0.0.0",
+			ClientIPTag: "93.184.216.0",
+		}, AnonymizedClientIPTags(netip.MustParseAddr("10.0.0.1"), ipv4, anonymizer))
+	})
+}
+
+func TestClientIPAnonymizerFromEnv(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		mode     string
+		key      string
+		expected ClientIPAnonymizer
+	}{
+		{name: "unset"},
+		{name: "none", mode: "none"},
+		{name: "truncate", mode: "Truncate", expected: ClientIPAnonymizer{Mode: ClientIPAnonymizationTruncate}},
+		{name: "hmac", mode: "hmac", key: "secret", expected: ClientIPAnonymizer{Mode: ClientIPAnonymizationHMAC, Key: []byte("secret")}},
+		{name: "hmac-without-key", mode: "hmac", expected: ClientIPAnonymizer{Mode: ClientIPAnonymizationOmit}},
+		{name: "omit", mode: "omit", expected: ClientIPAnonymizer{Mode: ClientIPAnonymizationOmit}},
+		{name: "unknown", mode: "scramble", expected: ClientIPAnonymizer{Mode: ClientIPAnonymizationOmit}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(EnvClientIPAnonymization, tc.mode)
+			t.Setenv(EnvClientIPAnonymizationKey, tc.key)
+			require.Equal(t, tc.expected, ClientIPAnonymizerFromEnv())
+		})
+	}
+}
+
+func TestClientIPTagsAnonymization(t *testing.T) {
+	remoteIP, clientIP := netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("93.184.216.34")
+	defer func(anonymizer func() ClientIPAnonymizer) { defaultClientIPAnonymizer = anonymizer }(defaultClientIPAnonymizer)
+	for _, tc := range []struct {
+		name     string
+		mode     string
+		expected map[string]string
+	}{
+		{name: "unset", expected: map[string]string{RemoteIPTag: "10.0.0.1", ClientIPTag: "93.184.216.34"}},
+		{name: "truncate", mode: "truncate", expected: map[string]string{RemoteIPTag: "10.0.0.0", ClientIPTag: "93.184.216.0"}},
+		{name: "omit", mode: "omit"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(EnvClientIPAnonymization, tc.mode)
+			defaultClientIPAnonymizer = sync.OnceValue(ClientIPAnonymizerFromEnv)
+			require.Equal(t, tc.expected, ClientIPTags(remoteIP, clientIP))
+
+			// The env is only read once
+			t.Setenv(EnvClientIPAnonymization, "hmac")
+			require.Equal(t, tc.expected, ClientIPTags(remoteIP, clientIP))
+		})
+	}
+}
+
+func TestNetworkTagsAnonymization(t *testing.T) {
+	headers := http.Header{"X-Forwarded-For": {"garbage, 93.184.216.34, 10.0.0.1"}}
+	info := ResolveClientIP(headers, true, "10.0.0.2", DefaultClientIPHeaders())
+	// The full client IP address is still resolved
+	require.Equal(t, netip.MustParseAddr("93.184.216.34"), info.ClientIP)
+
+	t.Run("truncate", func(t *testing.T) {
+		tags := NetworkTags(info, NetworkTagsConfig{
+			MaxChainLength: 4,
+			ObfuscateChain: true,
+			Anonymizer:     ClientIPAnonymizer{Mode: ClientIPAnonymizationTruncate},
+		})
+		require.Equal(t, map[string]string{
+			RemoteIPTag:        "10.0.0.0",
+			ClientIPTag:        "93.184.216.0",
+			ClientIPHeaderTag:  "x-forwarded-for",
+			ClientIPChainTag:   "invalid,93.184.216.0,private",
+			ClientIPVersionTag: "4",
+			ClientIPPublicTag:  "true",
+		}, tags)
+	})
+
+	t.Run("truncate-not-obfuscated", func(t *testing.T) {
+		tags := NetworkTags(info, NetworkTagsConfig{
+			MaxChainLength: 4,
+			Anonymizer:     ClientIPAnonymizer{Mode: ClientIPAnonymizationTruncate},
+		})
+		require.Equal(t, "invalid,93.184.216.0,10.0.0.0", tags[ClientIPChainTag])
+	})
+
+	t.Run("omit", func(t *testing.T) {
+		tags := NetworkTags(info, NetworkTagsConfig{
+			MaxChainLength: 4,
+			Anonymizer:     ClientIPAnonymizer{Mode: ClientIPAnonymizationOmit},
+		})
+		require.Equal(t, map[string]string{
+			ClientIPHeaderTag:  "x-forwarded-for",
+			ClientIPVersionTag: "4",
+			ClientIPPublicTag:  "true",
+		}, tags)
+	})
+}
@@ -0,0 +1,51 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+package httpsec
+
+import (
+	"strings"
+
+	"github.com/DataDog/appsec-internal-go/netip"
+)
+
+// grpcPeerBracketsUnescaper unescapes the brackets around IPv6 addresses, which
+// may be percent-encoded in gRPC peer URIs.
+var grpcPeerBracketsUnescaper = strings.NewReplacer("%5B", "[", "%5b", "[", "%5D", "]", "%5d", "]")
+
+// GRPCClientIP returns the remote IP address of the given gRPC peer address,
+// and the client IP address deduced from the given gRPC request metadata the
+// same way ClientIP() does with HTTP request headers. The resulting addresses
+// can be passed to ClientIPTags() to obtain the span tags.
+//
+// The metadata keys and monitored header names are expected to be lowercase,
+// as normalized by gRPC libraries. The peer address can either be a network
+// address as returned by `peer.Addr.String()` in grpc-go (e.g. `ip:port`), or
+// a gRPC peer URI (e.g. `ipv4:ip:port`, `ipv6:[ip]:port`). Unix socket peers
+// have no remote IP address, so the client IP address can only be deduced
+// from the metadata in that case.
+func GRPCClientIP(md map[string][]string, peerAddr string, monitoredHeaders []string) (remoteIP, clientIP netip.Addr) {
+	return clientIPFromRemoteIP(md, false, parseGRPCPeer(peerAddr), monitoredHeaders)
+}
+
+// parseGRPCPeer parses the IP address of the given gRPC peer address, which is
+// invalid for Unix socket peers.
+func parseGRPCPeer(peerAddr string) netip.Addr {
+	switch {
+	case peerAddr == "",
+		peerAddr[0] == '/', peerAddr[0] == '@', // Unix socket paths (including abstract ones)
+		strings.HasPrefix(peerAddr, "unix:"), strings.HasPrefix(peerAddr, "unix-abstract:"):
+		return netip.Addr{}
+	}
+
+	if addr, found := strings.CutPrefix(peerAddr, "ipv4:"); found {
+		return parseIP(addr)
+	}
+	if addr, found := strings.CutPrefix(peerAddr, "ipv6:"); found {
+		addr = grpcPeerBracketsUnescaper.Replace(addr)
+		return parseIP(addr)
+	}
+	return parseIP(peerAddr)
+}
@@ -0,0 +1,241 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+package httpsec
+
+import (
+	"os"
+	"strings"
+
+	"github.com/DataDog/appsec-internal-go/log"
+	"github.com/DataDog/appsec-internal-go/netip"
+)
+
+type (
+	// ClientIPPreset describes how to resolve the client IP address of
+	// requests going through a given cloud or CDN provider.
+	ClientIPPreset struct {
+		// Name is the name of the preset, as used to select it through the env.
+		Name string
+		// Headers is the list of headers carrying the client IP address, in
+		// order of precedence.
+		Headers []string
+		// Trust is the trust model used to pick the client IP address out of the
+		// headers.
+		Trust ClientIPTrustModel
+		// ProxyHops is the number of addresses appended by the provider to the
+		// forwarded chain after the client IP address, which are skipped with the
+		// TrustRightmostUntrusted trust model.
+		ProxyHops int
+		// PortSuffix is true when the value of the headers always ends with the
+		// `:port` of the client, including after unbracketed IPv6 addresses (e.g.
+		// `2001:db8::1:46532`), which is stripped with the TrustSingleValue trust
+		// model.
+		PortSuffix bool
+		// TrustedProxies is the set of IP ranges of the provider. When not nil,
+		// the headers are ignored unless the remote address belongs to it or is
+		// a private address (e.g. an internal load balancer), and its addresses
+		// are skipped with the TrustRightmostUntrusted trust model. The headers
+		// are also ignored when the remote address is not a valid IP address
+		// (e.g. empty, or a unix socket), as it cannot be checked. When nil, the
+		// headers are trusted regardless of the remote address, so that clients
+		// reaching the service without going through the provider can spoof
+		// their IP address by sending them, which is why the presets using the
+		// TrustSingleValue trust model should be given the provider ranges.
+		TrustedProxies *netip.PrefixSet
+	}
+
+	// ClientIPTrustModel is the way a [ClientIPPreset] picks the client IP
+	// address out of its headers.
+	ClientIPTrustModel uint8
+)
+
+const (
+	// TrustFirstPublic picks the first public IP address found in the headers,
+	// as ClientIP() does.
+	TrustFirstPublic ClientIPTrustModel = iota
+	// TrustSingleValue trusts the header to only hold the client IP address, as
+	// the provider overwrites any value sent by the client (e.g.
+	// `CF-Connecting-IP`).
+	TrustSingleValue
+	// TrustRightmostUntrusted walks the forwarded chain from the right, and
+	// picks the first address that is not a proxy, as the provider appends the
+	// address of its peer to any chain sent by the client (e.g.
+	// `X-Forwarded-For` with AWS ALB). The ProxyHops rightmost addresses, the
+	// trusted proxies and the private addresses are skipped.
+	TrustRightmostUntrusted
+)
+
+// clientIPPresets is the list of known client IP presets, by name.
+var clientIPPresets = map[string]ClientIPPreset{
+	"cloudflare": {
+		Headers: []string{"cf-connecting-ip", "cf-connecting-ipv6"},
+		Trust:   TrustSingleValue,
+	},
+	"fastly": {
+		Headers: []string{"fastly-client-ip"},
+		Trust:   TrustSingleValue,
+	},
+	"akamai": {
+		Headers: []string{"true-client-ip"},
+		Trust:   TrustSingleValue,
+	},
+	"aws-alb": {
+		Headers: []string{"x-forwarded-for"},
+		Trust:   TrustRightmostUntrusted,
+	},
+	"aws-cloudfront": {
+		// The `ip:port` address of the viewer, when enabled in the origin request
+		// policy
+		Headers:    []string{"cloudfront-viewer-address"},
+		Trust:      TrustSingleValue,
+		PortSuffix: true,
+	},
+	"gcp-lb": {
+		// `<client-ip>,<load-balancer-ip>` is appended to the chain
+		Headers:   []string{"x-forwarded-for"},
+		Trust:     TrustRightmostUntrusted,
+		ProxyHops: 1,
+	},
+	"azure-front-door": {
+		Headers: []string{"x-azure-clientip"},
+		Trust:   TrustSingleValue,
+	},
+}
+
+// LookupClientIPPreset returns the client IP preset with the given name, if
+// any. The known presets are `cloudflare`, `fastly`, `akamai`, `aws-alb`,
+// `aws-cloudfront`, `gcp-lb` and `azure-front-door`.
+func LookupClientIPPreset(name string) (ClientIPPreset, bool) {
+	preset, found := clientIPPresets[strings.ToLower(name)]
+	if !found {
+		return ClientIPPreset{}, false
+	}
+	preset.Name = strings.ToLower(name)
+	preset.Headers = append([]string(nil), preset.Headers...)
+	return preset, true
+}
+
+// ClientIPPresetFromEnv returns the client IP preset selected with the
+// `DD_TRACE_CLIENT_IP_PRESET` env var, with its trusted proxies loaded from
+// the file provided with the `DD_TRACE_CLIENT_IP_PRESET_RANGES` env var, if
+// any. It returns nil if no preset has been selected, and an error if the
+// preset is unknown or its ranges file could not be loaded. A warning is logged
+// when a preset using the TrustSingleValue trust model is selected without
+// ranges file, as its headers can then be spoofed (see
+// [ClientIPPreset.TrustedProxies]).
+func ClientIPPresetFromEnv() (*ClientIPPreset, error) {
+	name, rangesFile := clientIPPresetNameFromEnv()
+	if name == "" {
+		return nil, nil
+	}
+	preset, found := LookupClientIPPreset(name)
+	if !found {
+		return nil, log.Errorf("httpsec: unknown client IP preset %s configured through %s", name, EnvClientIPPreset)
+	}
+	if rangesFile == "" {
+		if preset.Trust == TrustSingleValue {
+			log.Warn("httpsec: no ranges file configured through %s for the client IP preset %s, its headers will be trusted from any remote address and can be spoofed", EnvClientIPPresetRanges, name)
+		}
+		return &preset, nil
+	}
+
+	file, err := os.Open(rangesFile)
+	if err != nil {
+		return nil, log.Errorf("httpsec: could not open the client IP preset ranges file %s: %w", rangesFile, err)
+	}
+	defer file.Close()
+	preset.TrustedProxies, err = netip.ReadPrefixSet(file)
+	if err != nil {
+		return nil, log.Errorf("httpsec: could not read the client IP preset ranges file %s: %w", rangesFile, err)
+	}
+	return &preset, nil
+}
+
+// ClientIP returns the remote IP address and the client IP address of the
+// request, resolved according to the preset. The client IP address is the
+// remote IP address when the headers are absent or not trusted. The headers
+// map is expected to use canonical MIME header keys if hasCanonicalHeaders is
+// true, or lowercase keys otherwise.
+func (p *ClientIPPreset) ClientIP(hdrs map[string][]string, hasCanonicalHeaders bool, remoteAddr string) (remoteIP, clientIP netip.Addr) {
+	remoteIP = parseIP(remoteAddr)
+	clientIP = remoteIP
+	if p.TrustedProxies != nil && (!remoteIP.IsValid() || netip.IsGlobal(remoteIP) && !p.TrustedProxies.Contains(remoteIP)) {
+		// The request is not known to come from the provider, so its headers may
+		// have been forged by the client
+		return remoteIP, clientIP
+	}
+
+	for _, headerName := range p.Headers {
+		headerValues, exists := lookupHeader(hdrs, hasCanonicalHeaders, headerName)
+		if !exists || len(headerValues) == 0 {
+			continue
+		}
+
+		var ip netip.Addr
+		switch p.Trust {
+		case TrustSingleValue:
+			ip = p.singleValue(headerValues[len(headerValues)-1])
+		case TrustRightmostUntrusted:
+			ip = p.rightmostUntrusted(headerName, headerValues)
+		default:
+			ip, _ = headerClientIP(headerName, headerValues)
+		}
+		if ip.IsValid() {
+			return remoteIP, ip
+		}
+	}
+	return remoteIP, clientIP
+}
+
+// singleValue returns the address held by the given header value, stripping
+// its port suffix first if the preset has one.
+func (p *ClientIPPreset) singleValue(value string) netip.Addr {
+	value = strings.TrimSpace(value)
+	if p.PortSuffix {
+		// parseIP() cannot tell the port of unbracketed IPv6 addresses apart
+		if colon := strings.LastIndexByte(value, ':'); colon >= 0 {
+			value = value[:colon]
+		}
+	}
+	return parseIP(value)
+}
+
+// rightmostUntrusted walks the comma-separated forwarded chain held by the
+// values of the given header from the right, and returns the first address
+// that is not a proxy. The leftmost address of the chain is returned if all of
+// them are proxies.
+func (p *ClientIPPreset) rightmostUntrusted(headerName string, headerValues []string) netip.Addr {
+	var (
+		hops     int
+		leftmost netip.Addr
+	)
+	for i := len(headerValues) - 1; i >= 0; i-- {
+		value := headerValues[i]
+		for value != "" {
+			var elem string
+			if comma := strings.LastIndexByte(value, ','); comma >= 0 {
+				value, elem = value[:comma], value[comma+1:]
+			} else {
+				value, elem = "", value
+			}
+
+			ip := headerElementIP(headerName, elem)
+			if !ip.IsValid() {
+				continue
+			}
+			leftmost = ip
+			if hops < p.ProxyHops {
+				hops++
+				continue
+			}
+			if !netip.IsGlobal(ip) || p.TrustedProxies != nil && p.TrustedProxies.Contains(ip) {
+				continue
+			}
+			return ip
+		}
+	}
+	return leftmost
+}
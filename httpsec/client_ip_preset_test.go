@@ -0,0 +1,248 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+package httpsec
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DataDog/appsec-internal-go/netip"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientIPPreset(t *testing.T) {
+	const (
+		clientIP   = "93.184.216.34"
+		spoofedIP  = "8.8.8.8"
+		providerIP = "173.245.48.1"
+	)
+	providerRanges := netip.NewPrefixSet(netip.MustParsePrefix("173.245.48.0/20"))
+
+	for _, tc := range []struct {
+		name             string
+		preset           string
+		trustedProxies   *netip.PrefixSet
+		remoteAddr       string
+		headers          map[string][]string
+		expectedClientIP string
+	}{
+		{
+			name:             "cloudflare",
+			preset:           "cloudflare",
+			remoteAddr:       providerIP,
+			headers:          map[string][]string{"cf-connecting-ip": {clientIP}, "x-forwarded-for": {spoofedIP}},
+			expectedClientIP: clientIP,
+		},
+		{
+			name:             "cloudflare-ipv6",
+			preset:           "cloudflare",
+			remoteAddr:       providerIP,
+			headers:          map[string][]string{"cf-connecting-ipv6": {"2a00:1450:4007:80e::200e"}},
+			expectedClientIP: "2a00:1450:4007:80e::200e",
+		},
+		{
+			name:             "cloudflare-trusted-proxy",
+			preset:           "cloudflare",
+			trustedProxies:   providerRanges,
+			remoteAddr:       providerIP + ":443",
+			headers:          map[string][]string{"cf-connecting-ip": {clientIP}},
+			expectedClientIP: clientIP,
+		},
+		{
+			name:             "cloudflare-internal-proxy",
+			preset:           "cloudflare",
+			trustedProxies:   providerRanges,
+			remoteAddr:       "10.0.0.1",
+			headers:          map[string][]string{"cf-connecting-ip": {clientIP}},
+			expectedClientIP: clientIP,
+		},
+		{
+			name:             "cloudflare-untrusted-proxy",
+			preset:           "cloudflare",
+			trustedProxies:   providerRanges,
+			remoteAddr:       clientIP,
+			headers:          map[string][]string{"cf-connecting-ip": {spoofedIP}},
+			expectedClientIP: clientIP,
+		},
+		{
+			name:           "cloudflare-unknown-proxy",
+			preset:         "cloudflare",
+			trustedProxies: providerRanges,
+			headers:        map[string][]string{"cf-connecting-ip": {spoofedIP}},
+		},
+		{
+			name:           "cloudflare-unix-socket-proxy",
+			preset:         "cloudflare",
+			trustedProxies: providerRanges,
+			remoteAddr:     "/run/app.sock",
+			headers:        map[string][]string{"cf-connecting-ip": {spoofedIP}},
+		},
+		{
+			name:             "cloudflare-unranged-unknown-proxy",
+			preset:           "cloudflare",
+			headers:          map[string][]string{"cf-connecting-ip": {clientIP}},
+			expectedClientIP: clientIP,
+		},
+		{
+			name:             "cloudflare-no-header",
+			preset:           "cloudflare",
+			remoteAddr:       providerIP,
+			headers:          map[string][]string{"x-forwarded-for": {clientIP}},
+			expectedClientIP: providerIP,
+		},
+		{
+			name:             "fastly",
+			preset:           "fastly",
+			remoteAddr:       providerIP,
+			headers:          map[string][]string{"fastly-client-ip": {clientIP}},
+			expectedClientIP: clientIP,
+		},
+		{
+			name:             "akamai",
+			preset:           "akamai",
+			remoteAddr:       providerIP,
+			headers:          map[string][]string{"true-client-ip": {clientIP}},
+			expectedClientIP: clientIP,
+		},
+		{
+			name:             "aws-alb",
+			preset:           "aws-alb",
+			remoteAddr:       "10.0.0.1",
+			headers:          map[string][]string{"x-forwarded-for": {spoofedIP + ", " + clientIP}},
+			expectedClientIP: clientIP,
+		},
+		{
+			name:             "aws-alb-internal-proxies",
+			preset:           "aws-alb",
+			remoteAddr:       "10.0.0.1",
+			headers:          map[string][]string{"x-forwarded-for": {spoofedIP + ", " + clientIP, "10.0.0.2"}},
+			expectedClientIP: clientIP,
+		},
+		{
+			name:             "aws-alb-trusted-proxies",
+			preset:           "aws-alb",
+			trustedProxies:   providerRanges,
+			remoteAddr:       "10.0.0.1",
+			headers:          map[string][]string{"x-forwarded-for": {spoofedIP + ", " + clientIP + ", " + providerIP}},
+			expectedClientIP: clientIP,
+		},
+		{
+			name:             "aws-alb-only-proxies",
+			preset:           "aws-alb",
+			remoteAddr:       "10.0.0.1",
+			headers:          map[string][]string{"x-forwarded-for": {"10.0.0.3, 10.0.0.2"}},
+			expectedClientIP: "10.0.0.3",
+		},
+		{
+			name:             "aws-cloudfront",
+			preset:           "aws-cloudfront",
+			remoteAddr:       providerIP,
+			headers:          map[string][]string{"cloudfront-viewer-address": {clientIP + ":46532"}},
+			expectedClientIP: clientIP,
+		},
+		{
+			name:             "aws-cloudfront-ipv6",
+			preset:           "aws-cloudfront",
+			remoteAddr:       providerIP,
+			headers:          map[string][]string{"cloudfront-viewer-address": {"2001:db8::1:443"}},
+			expectedClientIP: "2001:db8::1",
+		},
+		{
+			name:             "aws-cloudfront-ipv6-large-port",
+			preset:           "aws-cloudfront",
+			remoteAddr:       providerIP,
+			headers:          map[string][]string{"cloudfront-viewer-address": {"2a00:1450:4007:80e::200e:65535"}},
+			expectedClientIP: "2a00:1450:4007:80e::200e",
+		},
+		{
+			name:             "gcp-lb",
+			preset:           "gcp-lb",
+			remoteAddr:       "35.191.0.1",
+			headers:          map[string][]string{"x-forwarded-for": {spoofedIP + "," + clientIP + "," + providerIP}},
+			expectedClientIP: clientIP,
+		},
+		{
+			name:             "azure-front-door",
+			preset:           "azure-front-door",
+			remoteAddr:       providerIP,
+			headers:          map[string][]string{"x-azure-clientip": {clientIP}},
+			expectedClientIP: clientIP,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			preset, found := LookupClientIPPreset(tc.preset)
+			require.True(t, found)
+			require.Equal(t, tc.preset, preset.Name)
+			preset.TrustedProxies = tc.trustedProxies
+
+			headers := http.Header{}
+			for k, values := range tc.headers {
+				for _, v := range values {
+					headers.Add(k, v)
+				}
+			}
+			remoteIP, clientIP := preset.ClientIP(headers, true, tc.remoteAddr)
+			require.Equal(t, parseIP(tc.remoteAddr), remoteIP)
+			if tc.expectedClientIP == "" {
+				require.False(t, clientIP.IsValid())
+				return
+			}
+			require.Equal(t, netip.MustParseAddr(tc.expectedClientIP), clientIP)
+		})
+	}
+
+	t.Run("unknown", func(t *testing.T) {
+		_, found := LookupClientIPPreset("unknown")
+		require.False(t, found)
+	})
+}
+
+func TestClientIPPresetFromEnv(t *testing.T) {
+	t.Run("unset", func(t *testing.T) {
+		t.Setenv(EnvClientIPPreset, "")
+		preset, err := ClientIPPresetFromEnv()
+		require.NoError(t, err)
+		require.Nil(t, preset)
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		t.Setenv(EnvClientIPPreset, "unknown")
+		preset, err := ClientIPPresetFromEnv()
+		require.Error(t, err)
+		require.Nil(t, preset)
+	})
+
+	t.Run("no-ranges", func(t *testing.T) {
+		t.Setenv(EnvClientIPPreset, "Fastly")
+		t.Setenv(EnvClientIPPresetRanges, "")
+		preset, err := ClientIPPresetFromEnv()
+		require.NoError(t, err)
+		require.Equal(t, "fastly", preset.Name)
+		require.Equal(t, []string{"fastly-client-ip"}, preset.Headers)
+		require.Nil(t, preset.TrustedProxies)
+	})
+
+	t.Run("ranges", func(t *testing.T) {
+		rangesFile := filepath.Join(t.TempDir(), "ranges.txt")
+		require.NoError(t, os.WriteFile(rangesFile, []byte("173.245.48.0/20\n2400:cb00::/32\n"), 0o600))
+		t.Setenv(EnvClientIPPreset, "cloudflare")
+		t.Setenv(EnvClientIPPresetRanges, rangesFile)
+		preset, err := ClientIPPresetFromEnv()
+		require.NoError(t, err)
+		require.Equal(t, 2, preset.TrustedProxies.Len())
+		require.True(t, preset.TrustedProxies.Contains(netip.MustParseAddr("173.245.48.1")))
+	})
+
+	t.Run("ranges-not-found", func(t *testing.T) {
+		t.Setenv(EnvClientIPPreset, "cloudflare")
+		t.Setenv(EnvClientIPPresetRanges, filepath.Join(t.TempDir(), "i-do-not-exist"))
+		preset, err := ClientIPPresetFromEnv()
+		require.Error(t, err)
+		require.Nil(t, preset)
+	})
+}
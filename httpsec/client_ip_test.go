@@ -321,6 +321,385 @@ func TestClientIP(t *testing.T) {
 	}
 }
 
+func TestClientIPConflict(t *testing.T) {
+	ipv4Global := randGlobalIPv4().String()
+	ipv4Global2 := randGlobalIPv4().String()
+	ipv6Global := randGlobalIPv6().String()
+	ipv4Private := randPrivateIPv4().String()
+	monitoredHeaders := []string{"x-forwarded-for", "x-real-ip", "true-client-ip"}
+
+	for _, tc := range []struct {
+		name            string
+		headers         map[string]string
+		expectedHeaders []string
+	}{
+		{
+			name: "no-headers",
+		},
+		{
+			name:    "single-header",
+			headers: map[string]string{"x-forwarded-for": ipv4Global + "," + ipv6Global},
+		},
+		{
+			name: "same-ip",
+			headers: map[string]string{
+				"x-forwarded-for": ipv4Global + "," + ipv4Private,
+				"x-real-ip":       ipv4Global,
+			},
+		},
+		{
+			name: "private-ip",
+			headers: map[string]string{
+				"x-forwarded-for": ipv4Global,
+				"x-real-ip":       ipv4Private,
+			},
+		},
+		{
+			name: "unmonitored-header",
+			headers: map[string]string{
+				"x-forwarded-for": ipv4Global,
+				"x-client-ip":     ipv6Global,
+			},
+		},
+		{
+			name: "conflict",
+			headers: map[string]string{
+				"x-forwarded-for": ipv4Private + "," + ipv4Global,
+				"true-client-ip":  ipv4Global2,
+			},
+			expectedHeaders: []string{"x-forwarded-for", "true-client-ip"},
+		},
+		{
+			name: "conflict-all-headers",
+			headers: map[string]string{
+				"x-forwarded-for": ipv4Global,
+				"x-real-ip":       ipv4Global,
+				"true-client-ip":  ipv6Global,
+			},
+			expectedHeaders: []string{"x-forwarded-for", "x-real-ip", "true-client-ip"},
+		},
+	} {
+		for _, hasCanonicalMIMEHeaderKeys := range []bool{true, false} {
+			t.Run(fmt.Sprintf("%s/canonical-headers-%t", tc.name, hasCanonicalMIMEHeaderKeys), func(t *testing.T) {
+				headers := http.Header{}
+				for k, v := range tc.headers {
+					if hasCanonicalMIMEHeaderKeys {
+						headers.Add(k, v)
+					} else {
+						headers[k] = append(headers[k], v)
+					}
+				}
+
+				conflict, conflictHeaders := ClientIPConflict(headers, hasCanonicalMIMEHeaderKeys, monitoredHeaders)
+				tags := ClientIPConflictTags(conflict, conflictHeaders)
+				if tc.expectedHeaders == nil {
+					require.False(t, conflict)
+					require.Nil(t, conflictHeaders)
+					require.NotContains(t, tags, ClientIPConflictTag)
+				} else {
+					require.True(t, conflict)
+					require.Equal(t, tc.expectedHeaders, conflictHeaders)
+					require.Equal(t, strings.Join(tc.expectedHeaders, ","), tags[ClientIPConflictTag])
+				}
+			})
+		}
+	}
+}
+
+func TestClientIPForwarded(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		value      string
+		expectedIP netip.Addr
+	}{
+		{
+			name:       "ipv4",
+			value:      "for=93.184.216.34",
+			expectedIP: netip.MustParseAddr("93.184.216.34"),
+		},
+		{
+			name:       "ipv4-port",
+			value:      `for="93.184.216.34:4711";proto=http`,
+			expectedIP: netip.MustParseAddr("93.184.216.34"),
+		},
+		{
+			name:       "ipv6",
+			value:      `For="[2001:db8:cafe::17]"`,
+			expectedIP: netip.MustParseAddr("2001:db8:cafe::17"),
+		},
+		{
+			name:       "ipv6-port",
+			value:      `proto=https;for="[2001:db8:cafe::17]:4711";by=10.0.0.1`,
+			expectedIP: netip.MustParseAddr("2001:db8:cafe::17"),
+		},
+		{
+			name:       "multiple-elements",
+			value:      "for=10.0.0.2, for=_hidden, for=93.184.216.34;by=10.0.0.1",
+			expectedIP: netip.MustParseAddr("93.184.216.34"),
+		},
+		{
+			name:       "obfuscated",
+			value:      "for=unknown;by=93.184.216.34",
+			expectedIP: netip.Addr{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			headers := http.Header{}
+			headers.Add("forwarded", tc.value)
+			_, clientIP := ClientIP(headers, true, "", DefaultClientIPHeaders())
+			require.Equal(t, tc.expectedIP, clientIP)
+		})
+	}
+
+	t.Run("other-headers", func(t *testing.T) {
+		// The RFC 7239 syntax is only accepted in the Forwarded header
+		for _, header := range DefaultClientIPHeaders() {
+			if header == "forwarded" {
+				continue
+			}
+			headers := http.Header{}
+			headers.Add(header, "for=93.184.216.34")
+			_, clientIP := ClientIP(headers, true, "", DefaultClientIPHeaders())
+			require.False(t, clientIP.IsValid(), header)
+		}
+	})
+}
+
+func TestClientIPEmbeddedIPv4(t *testing.T) {
+	for _, tc := range []struct {
+		name             string
+		remoteAddr       string
+		header           string
+		expectedRemoteIP netip.Addr
+		expectedClientIP netip.Addr
+	}{
+		{
+			name:             "ipv4-mapped-remoteaddr",
+			remoteAddr:       "[::ffff:93.184.216.34]:443",
+			expectedRemoteIP: netip.MustParseAddr("93.184.216.34"),
+			expectedClientIP: netip.MustParseAddr("93.184.216.34"),
+		},
+		{
+			name:             "ipv4-mapped-header",
+			remoteAddr:       "::ffff:10.0.0.1",
+			header:           "::ffff:93.184.216.34",
+			expectedRemoteIP: netip.MustParseAddr("10.0.0.1"),
+			expectedClientIP: netip.MustParseAddr("93.184.216.34"),
+		},
+		{
+			name:             "nat64-header",
+			remoteAddr:       "10.0.0.1",
+			header:           "64:ff9b::10.0.0.2, 64:ff9b::5db8:d822",
+			expectedRemoteIP: netip.MustParseAddr("10.0.0.1"),
+			expectedClientIP: netip.MustParseAddr("93.184.216.34"),
+		},
+		{
+			name:             "ipv4-mapped-private-header",
+			remoteAddr:       "93.184.216.34",
+			header:           "::ffff:10.0.0.2",
+			expectedRemoteIP: netip.MustParseAddr("93.184.216.34"),
+			expectedClientIP: netip.MustParseAddr("93.184.216.34"),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			headers := http.Header{}
+			if tc.header != "" {
+				headers.Add("x-forwarded-for", tc.header)
+			}
+			remoteIP, clientIP := ClientIP(headers, true, tc.remoteAddr, []string{"x-forwarded-for"})
+			require.Equal(t, tc.expectedRemoteIP, remoteIP)
+			require.Equal(t, tc.expectedClientIP, clientIP)
+			tags := ClientIPTags(remoteIP, clientIP)
+			require.Equal(t, tc.expectedRemoteIP.String(), tags[RemoteIPTag])
+			require.Equal(t, tc.expectedClientIP.String(), tags[ClientIPTag])
+		})
+	}
+}
+
+func TestGRPCClientIP(t *testing.T) {
+	for _, tc := range []struct {
+		name             string
+		peerAddr         string
+		md               map[string][]string
+		expectedRemoteIP netip.Addr
+		expectedClientIP netip.Addr
+	}{
+		{
+			name:             "ipv4-port",
+			peerAddr:         "93.184.216.34:50051",
+			expectedRemoteIP: netip.MustParseAddr("93.184.216.34"),
+			expectedClientIP: netip.MustParseAddr("93.184.216.34"),
+		},
+		{
+			name:             "ipv6-port",
+			peerAddr:         "[2a00:1450:4007:80e::200e]:50051",
+			expectedRemoteIP: netip.MustParseAddr("2a00:1450:4007:80e::200e"),
+			expectedClientIP: netip.MustParseAddr("2a00:1450:4007:80e::200e"),
+		},
+		{
+			name:             "ipv4-uri",
+			peerAddr:         "ipv4:10.0.0.1:50051",
+			md:               map[string][]string{"x-forwarded-for": {"93.184.216.34"}},
+			expectedRemoteIP: netip.MustParseAddr("10.0.0.1"),
+			expectedClientIP: netip.MustParseAddr("93.184.216.34"),
+		},
+		{
+			name:             "ipv6-uri",
+			peerAddr:         "ipv6:[::1]:50051",
+			expectedRemoteIP: netip.MustParseAddr("::1"),
+			expectedClientIP: netip.MustParseAddr("::1"),
+		},
+		{
+			name:             "ipv6-uri-encoded",
+			peerAddr:         "ipv6:%5B::ffff:93.184.216.34%5D:50051",
+			expectedRemoteIP: netip.MustParseAddr("93.184.216.34"),
+			expectedClientIP: netip.MustParseAddr("93.184.216.34"),
+		},
+		{
+			name:             "unix-socket",
+			peerAddr:         "/var/run/app.sock",
+			md:               map[string][]string{"x-real-ip": {"10.0.0.2"}},
+			expectedClientIP: netip.MustParseAddr("10.0.0.2"),
+		},
+		{
+			name:     "unix-uri",
+			peerAddr: "unix:/var/run/app.sock",
+		},
+		{
+			name:     "unix-abstract-socket",
+			peerAddr: "@app",
+		},
+		{
+			name:             "no-peer",
+			md:               map[string][]string{"x-forwarded-for": {"10.0.0.2, 93.184.216.34"}},
+			expectedClientIP: netip.MustParseAddr("93.184.216.34"),
+		},
+		{
+			name:             "invalid-peer",
+			peerAddr:         "2a00:1450:4007:80e::200e:50051",
+			md:               map[string][]string{"true-client-ip": {"93.184.216.34"}},
+			expectedRemoteIP: netip.Addr{},
+			expectedClientIP: netip.MustParseAddr("93.184.216.34"),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			remoteIP, clientIP := GRPCClientIP(tc.md, tc.peerAddr, DefaultClientIPHeaders())
+			require.Equal(t, tc.expectedRemoteIP, remoteIP)
+			require.Equal(t, tc.expectedClientIP, clientIP)
+
+			tags := ClientIPTags(remoteIP, clientIP)
+			if tc.expectedRemoteIP.IsValid() {
+				require.Equal(t, tc.expectedRemoteIP.String(), tags[RemoteIPTag])
+			} else {
+				require.NotContains(t, tags, RemoteIPTag)
+			}
+			if tc.expectedClientIP.IsValid() {
+				require.Equal(t, tc.expectedClientIP.String(), tags[ClientIPTag])
+			} else {
+				require.NotContains(t, tags, ClientIPTag)
+			}
+		})
+	}
+}
+
+func TestMonitoredClientIPHeaders(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		t.Setenv(EnvClientIPHeader, "")
+		headers := MonitoredClientIPHeaders()
+		require.Equal(t, DefaultClientIPHeaders(), headers)
+		require.Contains(t, headers, "x-forwarded-for")
+		require.Contains(t, headers, "forwarded")
+
+		// The returned list is a copy that can be modified by the caller
+		headers[0] = "x-custom-ip"
+		require.Equal(t, "x-forwarded-for", DefaultClientIPHeaders()[0])
+	})
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv(EnvClientIPHeader, "X-Custom-Ip")
+		require.Equal(t, []string{"x-custom-ip"}, MonitoredClientIPHeaders())
+	})
+}
+
+func TestClientIPAllocations(t *testing.T) {
+	for name, headers := range clientIPBenchmarkHeaders() {
+		for _, hasCanonicalMIMEHeaderKeys := range []bool{true, false} {
+			t.Run(fmt.Sprintf("%s/canonical-headers-%t", name, hasCanonicalMIMEHeaderKeys), func(t *testing.T) {
+				hdrs := clientIPBenchmarkHeadersMap(headers, hasCanonicalMIMEHeaderKeys)
+				monitoredHeaders := DefaultClientIPHeaders()
+				allocs := testing.AllocsPerRun(100, func() {
+					_, _ = ClientIP(hdrs, hasCanonicalMIMEHeaderKeys, "10.0.0.1:12345", monitoredHeaders)
+					_, _ = ClientIPConflict(hdrs, hasCanonicalMIMEHeaderKeys, monitoredHeaders)
+				})
+				require.Zero(t, allocs)
+			})
+		}
+	}
+
+	t.Run("custom-header", func(t *testing.T) {
+		hdrs := http.Header{"X-Custom-Ip": {"10.0.0.2, 93.184.216.34"}}
+		monitoredHeaders := []string{"x-custom-ip"}
+		allocs := testing.AllocsPerRun(100, func() {
+			_, _ = ClientIP(hdrs, true, "10.0.0.1:12345", monitoredHeaders)
+		})
+		require.Zero(t, allocs)
+	})
+}
+
+func BenchmarkClientIP(b *testing.B) {
+	for name, headers := range clientIPBenchmarkHeaders() {
+		for _, hasCanonicalMIMEHeaderKeys := range []bool{true, false} {
+			b.Run(fmt.Sprintf("%s/canonical-headers-%t", name, hasCanonicalMIMEHeaderKeys), func(b *testing.B) {
+				hdrs := clientIPBenchmarkHeadersMap(headers, hasCanonicalMIMEHeaderKeys)
+				monitoredHeaders := DefaultClientIPHeaders()
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					_, _ = ClientIP(hdrs, hasCanonicalMIMEHeaderKeys, "10.0.0.1:12345", monitoredHeaders)
+				}
+			})
+		}
+	}
+}
+
+// clientIPBenchmarkHeaders returns typical client IP headers, by name of
+// scenario.
+func clientIPBenchmarkHeaders() map[string]map[string][]string {
+	return map[string]map[string][]string{
+		"no-headers": {},
+		"xff-single": {
+			"x-forwarded-for": {"93.184.216.34"},
+		},
+		"xff-chain": {
+			"x-forwarded-for": {"10.0.0.2, 172.16.0.3, 93.184.216.34, 2a00:1450:4007:80e::200e"},
+		},
+		"xff-chain-private": {
+			"x-forwarded-for": {"10.0.0.2, 172.16.0.3", "192.168.0.4"},
+		},
+		"xff-ports": {
+			"x-forwarded-for": {"10.0.0.2:1234, [fd00::1]:4321, 93.184.216.34:443"},
+		},
+		"multiple-headers": {
+			"x-forwarded-for": {"10.0.0.2, 172.16.0.3"},
+			"x-real-ip":       {"192.168.0.4"},
+			"forwarded":       {"for=10.0.0.2;proto=https, for=\"[2a00:1450:4007:80e::200e]:443\""},
+		},
+	}
+}
+
+// clientIPBenchmarkHeadersMap converts the given headers into a headers map,
+// with canonical MIME header keys if requested.
+func clientIPBenchmarkHeadersMap(headers map[string][]string, hasCanonicalMIMEHeaderKeys bool) map[string][]string {
+	hdrs := make(http.Header, len(headers))
+	for k, v := range headers {
+		if hasCanonicalMIMEHeaderKeys {
+			k = http.CanonicalHeaderKey(k)
+		}
+		hdrs[k] = v
+	}
+	return hdrs
+}
+
 func randIPv4() netip.Addr {
 	return netip.IPv4(uint8(rand.Uint32()), uint8(rand.Uint32()), uint8(rand.Uint32()), uint8(rand.Uint32()))
 }
@@ -337,7 +716,7 @@ func randIPv6() netip.Addr {
 func randGlobalIPv4() netip.Addr {
 	for {
 		ip := randIPv4()
-		if isGlobal(ip) {
+		if netip.IsGlobal(ip) {
 			return ip
 		}
 	}
@@ -346,7 +725,7 @@ func randGlobalIPv4() netip.Addr {
 func randGlobalIPv6() netip.Addr {
 	for {
 		ip := randIPv6()
-		if isGlobal(ip) {
+		if netip.IsGlobal(ip) {
 			return ip
 		}
 	}
@@ -355,7 +734,7 @@ func randGlobalIPv6() netip.Addr {
 func randPrivateIPv4() netip.Addr {
 	for {
 		ip := randIPv4()
-		if !isGlobal(ip) && ip.IsPrivate() {
+		if !netip.IsGlobal(ip) && ip.IsPrivate() {
 			return ip
 		}
 	}
@@ -364,7 +743,7 @@ func randPrivateIPv4() netip.Addr {
 func randPrivateIPv6() netip.Addr {
 	for {
 		ip := randIPv6()
-		if !isGlobal(ip) && ip.IsPrivate() {
+		if !netip.IsGlobal(ip) && ip.IsPrivate() {
 			return ip
 		}
 	}
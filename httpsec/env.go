@@ -0,0 +1,104 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+package httpsec
+
+import (
+	"os"
+	"strings"
+
+	"github.com/DataDog/appsec-internal-go/internal/env"
+	"github.com/DataDog/appsec-internal-go/log"
+)
+
+// The env vars are read by this package rather than by the appsec package, so
+// that it does not depend on the security rules and API Security samplers.
+const (
+	// EnvClientIPHeader is the env var used to provide the name of the only
+	// header to monitor to find the client IP address.
+	EnvClientIPHeader = "DD_TRACE_CLIENT_IP_HEADER"
+	// EnvClientIPPreset is the env var used to select the cloud or CDN provider
+	// preset used to resolve the client IP address, such as `cloudflare` or
+	// `aws-alb`.
+	EnvClientIPPreset = "DD_TRACE_CLIENT_IP_PRESET"
+	// EnvClientIPPresetRanges is the env var used to provide a path to a local
+	// file listing the IP ranges of the provider selected with
+	// EnvClientIPPreset.
+	EnvClientIPPresetRanges = "DD_TRACE_CLIENT_IP_PRESET_RANGES"
+	// EnvClientIPChainMaxLength is the env var used to set the maximum number
+	// of addresses of the forwarded chain reported in the span tags, 0
+	// disabling the reporting of the forwarded chain.
+	EnvClientIPChainMaxLength = "DD_TRACE_CLIENT_IP_CHAIN_MAX_LENGTH"
+	// EnvClientIPChainObfuscation is the env var used to enable/disable the
+	// obfuscation of the private addresses of the forwarded chain reported in
+	// the span tags.
+	EnvClientIPChainObfuscation = "DD_TRACE_CLIENT_IP_CHAIN_OBFUSCATION"
+	// EnvClientIPAnonymization is the env var used to select how IP addresses
+	// are anonymized in the span tags, either `none`, `truncate`, `hmac` or
+	// `omit`.
+	EnvClientIPAnonymization = "DD_TRACE_CLIENT_IP_ANONYMIZATION"
+	// EnvClientIPAnonymizationKey is the env var used to provide the secret key
+	// of the `hmac` anonymization mode.
+	EnvClientIPAnonymizationKey = "DD_TRACE_CLIENT_IP_ANONYMIZATION_KEY"
+)
+
+// DefaultClientIPChainMaxLength is the default maximum number of addresses of
+// the forwarded chain reported in the span tags.
+const DefaultClientIPChainMaxLength = 16
+
+// clientIPHeaderFromEnv returns the lowercase name of the header to retrieve
+// the client IP address from, as configured through the env. It returns an
+// empty string if the env var is not set, in which case the default list of
+// monitored headers should be used.
+func clientIPHeaderFromEnv() string {
+	value := strings.ToLower(strings.TrimSpace(os.Getenv(EnvClientIPHeader)))
+	if value != "" {
+		log.Debug("httpsec: using the client IP header %s configured through %s", value, EnvClientIPHeader)
+	}
+	return value
+}
+
+// clientIPPresetNameFromEnv returns the lowercase name of the client IP preset,
+// along with the path to the file listing the IP ranges of its provider, as
+// configured through the env. The name is empty if the env var is not set, and
+// the ranges file path is empty if the provider ranges should not be checked.
+func clientIPPresetNameFromEnv() (name string, rangesFile string) {
+	name = strings.ToLower(strings.TrimSpace(os.Getenv(EnvClientIPPreset)))
+	if name == "" {
+		return "", ""
+	}
+	rangesFile = strings.TrimSpace(os.Getenv(EnvClientIPPresetRanges))
+	log.Debug("httpsec: using the client IP preset %s configured through %s (ranges file: %q)", name, EnvClientIPPreset, rangesFile)
+	return name, rangesFile
+}
+
+// clientIPChainFromEnv returns the maximum number of addresses of the
+// forwarded chain to report in the span tags, and whether its private
+// addresses should be obfuscated, as configured through the env. The
+// obfuscation is enabled by default.
+func clientIPChainFromEnv() (maxLength int, obfuscate bool) {
+	maxLength = env.Int(EnvClientIPChainMaxLength, DefaultClientIPChainMaxLength)
+	if maxLength < 0 {
+		env.LogUnexpectedValue(EnvClientIPChainMaxLength, maxLength, "expecting a positive value", DefaultClientIPChainMaxLength)
+		maxLength = DefaultClientIPChainMaxLength
+	}
+	return maxLength, env.Bool(EnvClientIPChainObfuscation, true)
+}
+
+// clientIPAnonymizationFromEnv returns the lowercase name of the IP address
+// anonymization mode, along with its secret key, as configured through the
+// env. The mode is empty if the env var is not set, in which case IP addresses
+// are reported as-is.
+func clientIPAnonymizationFromEnv() (mode string, key []byte) {
+	mode = strings.ToLower(strings.TrimSpace(os.Getenv(EnvClientIPAnonymization)))
+	if mode == "" {
+		return "", nil
+	}
+	log.Debug("httpsec: using the client IP anonymization mode %s configured through %s", mode, EnvClientIPAnonymization)
+	if value := os.Getenv(EnvClientIPAnonymizationKey); value != "" {
+		key = []byte(value)
+	}
+	return mode, key
+}
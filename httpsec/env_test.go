@@ -0,0 +1,123 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+package httpsec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientIPHeaderFromEnv(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		env      string
+		expected string
+	}{
+		{
+			name:     "lowercase",
+			env:      "x-custom-ip",
+			expected: "x-custom-ip",
+		},
+		{
+			name:     "canonical",
+			env:      "X-Custom-Ip",
+			expected: "x-custom-ip",
+		},
+		{
+			name:     "spaces",
+			env:      "  x-custom-ip ",
+			expected: "x-custom-ip",
+		},
+		{
+			name:     "empty-string",
+			env:      "",
+			expected: "",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(EnvClientIPHeader, tc.env)
+			require.Equal(t, tc.expected, clientIPHeaderFromEnv())
+		})
+	}
+}
+
+func TestClientIPPresetNameFromEnv(t *testing.T) {
+	t.Run("unset", func(t *testing.T) {
+		t.Setenv(EnvClientIPPreset, "")
+		t.Setenv(EnvClientIPPresetRanges, "/etc/ranges.txt")
+		name, rangesFile := clientIPPresetNameFromEnv()
+		require.Empty(t, name)
+		require.Empty(t, rangesFile)
+	})
+
+	t.Run("preset", func(t *testing.T) {
+		t.Setenv(EnvClientIPPreset, " Cloudflare ")
+		t.Setenv(EnvClientIPPresetRanges, "")
+		name, rangesFile := clientIPPresetNameFromEnv()
+		require.Equal(t, "cloudflare", name)
+		require.Empty(t, rangesFile)
+	})
+
+	t.Run("preset-ranges", func(t *testing.T) {
+		t.Setenv(EnvClientIPPreset, "cloudflare")
+		t.Setenv(EnvClientIPPresetRanges, "/etc/ranges.txt")
+		name, rangesFile := clientIPPresetNameFromEnv()
+		require.Equal(t, "cloudflare", name)
+		require.Equal(t, "/etc/ranges.txt", rangesFile)
+	})
+}
+
+func TestClientIPChainFromEnv(t *testing.T) {
+	for _, tc := range []struct {
+		name              string
+		maxLength         string
+		obfuscation       string
+		expectedMaxLength int
+		expectedObfuscate bool
+	}{
+		{name: "default", expectedMaxLength: DefaultClientIPChainMaxLength, expectedObfuscate: true},
+		{name: "max-length", maxLength: "4", expectedMaxLength: 4, expectedObfuscate: true},
+		{name: "disabled", maxLength: "0", expectedMaxLength: 0, expectedObfuscate: true},
+		{name: "negative", maxLength: "-1", expectedMaxLength: DefaultClientIPChainMaxLength, expectedObfuscate: true},
+		{name: "invalid", maxLength: "many", expectedMaxLength: DefaultClientIPChainMaxLength, expectedObfuscate: true},
+		{name: "no-obfuscation", obfuscation: "false", expectedMaxLength: DefaultClientIPChainMaxLength, expectedObfuscate: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(EnvClientIPChainMaxLength, tc.maxLength)
+			t.Setenv(EnvClientIPChainObfuscation, tc.obfuscation)
+			maxLength, obfuscate := clientIPChainFromEnv()
+			require.Equal(t, tc.expectedMaxLength, maxLength)
+			require.Equal(t, tc.expectedObfuscate, obfuscate)
+		})
+	}
+}
+
+func TestClientIPAnonymizationFromEnv(t *testing.T) {
+	t.Run("unset", func(t *testing.T) {
+		t.Setenv(EnvClientIPAnonymization, "")
+		t.Setenv(EnvClientIPAnonymizationKey, "secret")
+		mode, key := clientIPAnonymizationFromEnv()
+		require.Empty(t, mode)
+		require.Nil(t, key)
+	})
+
+	t.Run("mode", func(t *testing.T) {
+		t.Setenv(EnvClientIPAnonymization, " Truncate ")
+		t.Setenv(EnvClientIPAnonymizationKey, "")
+		mode, key := clientIPAnonymizationFromEnv()
+		require.Equal(t, "truncate", mode)
+		require.Nil(t, key)
+	})
+
+	t.Run("mode-key", func(t *testing.T) {
+		t.Setenv(EnvClientIPAnonymization, "hmac")
+		t.Setenv(EnvClientIPAnonymizationKey, "secret")
+		mode, key := clientIPAnonymizationFromEnv()
+		require.Equal(t, "hmac", mode)
+		require.Equal(t, []byte("secret"), key)
+	})
+}
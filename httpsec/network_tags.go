@@ -0,0 +1,187 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+package httpsec
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/DataDog/appsec-internal-go/netip"
+)
+
+const (
+	// ClientIPHeaderTag is the tag name used for the name of the header the
+	// client IP address was found in.
+	ClientIPHeaderTag = "http.client_ip_header"
+	// ClientIPChainTag is the tag name used for the comma-separated forwarded
+	// chain of addresses held by the header the client IP address was found in.
+	ClientIPChainTag = "http.client_ip_chain"
+	// ClientIPVersionTag is the tag name used for the version of the client IP
+	// address, either `4` or `6`.
+	ClientIPVersionTag = "http.client_ip_version"
+	// ClientIPPublicTag is the tag name used to report whether the client IP
+	// address is a globally reachable address, either `true` or `false`.
+	ClientIPPublicTag = "http.client_ip_public"
+)
+
+const (
+	// chainTruncatedMarker is the leading element of forwarded chains longer
+	// than the configured maximum length.
+	chainTruncatedMarker = "..."
+	// chainPrivateAddress replaces the private addresses of obfuscated
+	// forwarded chains.
+	chainPrivateAddress = "private"
+	// chainInvalidAddress replaces the elements of forwarded chains that are not
+	// valid addresses.
+	chainInvalidAddress = "invalid"
+	// maxChainElementLength is the maximum length of the forwarded chain
+	// elements reported as-is, which is longer than any `[ipv6]:port` address.
+	// Longer elements, such as RFC 7239 elements with many parameters, are
+	// reported as the address they hold.
+	maxChainElementLength = 64
+)
+
+// NetworkTagsConfig is the configuration of the span tags returned by
+// NetworkTags().
+type NetworkTagsConfig struct {
+	// MaxChainLength is the maximum number of addresses of the forwarded chain
+	// to report. The rightmost addresses, closest to the server, are kept. The
+	// forwarded chain is not reported when 0.
+	MaxChainLength int
+	// ObfuscateChain enables the obfuscation of the forwarded chain, whose
+	// private addresses are replaced by `private`, so as not to disclose the
+	// internal network topology, and whose elements are reported as the address
+	// they hold. The elements that are not valid addresses are always replaced
+	// by `invalid`, so as not to report arbitrary values sent by the client.
+	ObfuscateChain bool
+	// Anonymizer anonymizes the IP addresses reported in the tags, including
+	// the ones of the forwarded chain. The forwarded chain is not reported when
+	// IP addresses are omitted.
+	Anonymizer ClientIPAnonymizer
+}
+
+// NetworkTagsConfigFromEnv returns the configuration of the span tags returned
+// by NetworkTags(), as configured through the env.
+func NetworkTagsConfigFromEnv() NetworkTagsConfig {
+	maxLength, obfuscate := clientIPChainFromEnv()
+	return NetworkTagsConfig{
+		MaxChainLength: maxLength,
+		ObfuscateChain: obfuscate,
+		Anonymizer:     ClientIPAnonymizerFromEnv(),
+	}
+}
+
+// NetworkTags returns the span tags returned by AnonymizedClientIPTags(), along
+// with the tags describing where the client IP address was found and what it
+// is: `http.client_ip_header`, `http.client_ip_chain`, `http.client_ip_version`
+// and `http.client_ip_public`. The header and chain tags are present only if
+// the client IP address was found in a header.
+func NetworkTags(info ClientIPInfo, cfg NetworkTagsConfig) map[string]string {
+	tags := AnonymizedClientIPTags(info.RemoteIP, info.ClientIP, cfg.Anonymizer)
+	if !info.ClientIP.IsValid() {
+		return tags
+	}
+	if tags == nil {
+		tags = make(map[string]string, 3)
+	}
+
+	if info.ClientIP.Is4() {
+		tags[ClientIPVersionTag] = "4"
+	} else {
+		tags[ClientIPVersionTag] = "6"
+	}
+	tags[ClientIPPublicTag] = strconv.FormatBool(netip.IsGlobal(info.ClientIP))
+
+	if info.Header == "" {
+		return tags
+	}
+	tags[ClientIPHeaderTag] = info.Header
+	if chain := forwardedChain(info.Header, info.HeaderValues, cfg); chain != "" {
+		tags[ClientIPChainTag] = chain
+	}
+	return tags
+}
+
+// forwardedChain returns the comma-separated forwarded chain of addresses held
+// by the values of the given header, bounded and obfuscated according to the
+// given configuration.
+func forwardedChain(headerName string, headerValues []string, cfg NetworkTagsConfig) string {
+	if cfg.MaxChainLength <= 0 || cfg.Anonymizer.Mode == ClientIPAnonymizationOmit {
+		return ""
+	}
+
+	// Walk the chain from the right, keeping the rightmost addresses, appended
+	// by the proxies closest to the server, as the leftmost ones can be freely
+	// set by the client. The scan stops once the chain is known to be too long,
+	// so that its cost does not depend on the size of the header.
+	var (
+		chain     []string
+		truncated bool
+	)
+scan:
+	for i := len(headerValues) - 1; i >= 0; i-- {
+		value := headerValues[i]
+		for value != "" {
+			var elem string
+			if comma := strings.LastIndexByte(value, ','); comma >= 0 {
+				value, elem = value[:comma], value[comma+1:]
+			} else {
+				value, elem = "", value
+			}
+			if elem = strings.TrimSpace(elem); elem == "" {
+				continue
+			}
+			if len(chain) == cfg.MaxChainLength {
+				truncated = true
+				break scan
+			}
+			if cfg.ObfuscateChain || cfg.Anonymizer.Mode != ClientIPAnonymizationNone {
+				elem = obfuscateChainElement(headerName, elem, cfg)
+			} else {
+				elem = rawChainElement(headerName, elem)
+			}
+			chain = append(chain, elem)
+		}
+	}
+
+	if truncated {
+		chain = append(chain, chainTruncatedMarker)
+	}
+	slices.Reverse(chain)
+	return strings.Join(chain, ",")
+}
+
+// obfuscateChainElement returns the address of the given forwarded chain
+// element, anonymized according to the given configuration, or its obfuscated
+// replacement if it is invalid, or private and the chain is obfuscated.
+func obfuscateChainElement(headerName string, elem string, cfg NetworkTagsConfig) string {
+	ip := headerElementIP(headerName, elem)
+	if !ip.IsValid() {
+		return chainInvalidAddress
+	}
+	if cfg.ObfuscateChain && !netip.IsGlobal(ip) {
+		return chainPrivateAddress
+	}
+	if value, ok := cfg.Anonymizer.Anonymize(ip); ok {
+		return value
+	}
+	return chainInvalidAddress
+}
+
+// rawChainElement returns the given forwarded chain element as-is when it holds
+// a valid address and is not too long, the address it holds when it is too
+// long, or its replacement if it is invalid.
+func rawChainElement(headerName string, elem string) string {
+	ip := headerElementIP(headerName, elem)
+	if !ip.IsValid() {
+		return chainInvalidAddress
+	}
+	if len(elem) > maxChainElementLength {
+		return ip.String()
+	}
+	return elem
+}
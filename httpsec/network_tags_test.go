@@ -0,0 +1,231 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+package httpsec
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetworkTags(t *testing.T) {
+	defaultConfig := NetworkTagsConfig{MaxChainLength: 4, ObfuscateChain: true}
+
+	for _, tc := range []struct {
+		name         string
+		headers      map[string]string
+		remoteAddr   string
+		config       *NetworkTagsConfig
+		expectedTags map[string]string
+	}{
+		{
+			name:       "remote-ip",
+			remoteAddr: "93.184.216.34:443",
+			expectedTags: map[string]string{
+				RemoteIPTag:        "93.184.216.34",
+				ClientIPTag:        "93.184.216.34",
+				ClientIPVersionTag: "4",
+				ClientIPPublicTag:  "true",
+			},
+		},
+		{
+			name:       "private-remote-ip",
+			remoteAddr: "[fd00::1]:443",
+			expectedTags: map[string]string{
+				RemoteIPTag:        "fd00::1",
+				ClientIPTag:        "fd00::1",
+				ClientIPVersionTag: "6",
+				ClientIPPublicTag:  "false",
+			},
+		},
+		{
+			name:       "forwarded-chain",
+			headers:    map[string]string{"X-Forwarded-For": "10.0.0.1, 93.184.216.34, 2a00:1450:4007:80e::200e, 10.0.0.2"},
+			remoteAddr: "10.0.0.3",
+			expectedTags: map[string]string{
+				RemoteIPTag:        "10.0.0.3",
+				ClientIPTag:        "93.184.216.34",
+				ClientIPHeaderTag:  "x-forwarded-for",
+				ClientIPChainTag:   "private,93.184.216.34,2a00:1450:4007:80e::200e,private",
+				ClientIPVersionTag: "4",
+				ClientIPPublicTag:  "true",
+			},
+		},
+		{
+			name:       "forwarded-chain-invalid",
+			headers:    map[string]string{"X-Forwarded-For": "<script>, 93.184.216.34:8080"},
+			remoteAddr: "10.0.0.3",
+			expectedTags: map[string]string{
+				RemoteIPTag:        "10.0.0.3",
+				ClientIPTag:        "93.184.216.34",
+				ClientIPHeaderTag:  "x-forwarded-for",
+				ClientIPChainTag:   "invalid,93.184.216.34",
+				ClientIPVersionTag: "4",
+				ClientIPPublicTag:  "true",
+			},
+		},
+		{
+			name:       "forwarded-chain-not-obfuscated",
+			headers:    map[string]string{"X-Forwarded-For": "10.0.0.1, 93.184.216.34:8080"},
+			remoteAddr: "10.0.0.3",
+			config:     &NetworkTagsConfig{MaxChainLength: 4},
+			expectedTags: map[string]string{
+				RemoteIPTag:        "10.0.0.3",
+				ClientIPTag:        "93.184.216.34",
+				ClientIPHeaderTag:  "x-forwarded-for",
+				ClientIPChainTag:   "10.0.0.1,93.184.216.34:8080",
+				ClientIPVersionTag: "4",
+				ClientIPPublicTag:  "true",
+			},
+		},
+		{
+			name:       "forwarded-chain-not-obfuscated-invalid",
+			headers:    map[string]string{"X-Forwarded-For": "<script>, 93.184.216.34"},
+			remoteAddr: "10.0.0.3",
+			config:     &NetworkTagsConfig{MaxChainLength: 4},
+			expectedTags: map[string]string{
+				RemoteIPTag:        "10.0.0.3",
+				ClientIPTag:        "93.184.216.34",
+				ClientIPHeaderTag:  "x-forwarded-for",
+				ClientIPChainTag:   "invalid,93.184.216.34",
+				ClientIPVersionTag: "4",
+				ClientIPPublicTag:  "true",
+			},
+		},
+		{
+			name:       "forwarded-not-obfuscated-long-element",
+			headers:    map[string]string{"Forwarded": "for=93.184.216.34;proto=https;host=" + strings.Repeat("a", 64)},
+			remoteAddr: "10.0.0.3",
+			config:     &NetworkTagsConfig{MaxChainLength: 4},
+			expectedTags: map[string]string{
+				RemoteIPTag:        "10.0.0.3",
+				ClientIPTag:        "93.184.216.34",
+				ClientIPHeaderTag:  "forwarded",
+				ClientIPChainTag:   "93.184.216.34",
+				ClientIPVersionTag: "4",
+				ClientIPPublicTag:  "true",
+			},
+		},
+		{
+			name:       "forwarded-chain-truncated",
+			headers:    map[string]string{"X-Forwarded-For": "8.8.8.8, 8.8.4.4, 1.1.1.1, 93.184.216.34, 10.0.0.1, 10.0.0.2"},
+			remoteAddr: "10.0.0.3",
+			expectedTags: map[string]string{
+				RemoteIPTag:        "10.0.0.3",
+				ClientIPTag:        "8.8.8.8",
+				ClientIPHeaderTag:  "x-forwarded-for",
+				ClientIPChainTag:   "...,1.1.1.1,93.184.216.34,private,private",
+				ClientIPVersionTag: "4",
+				ClientIPPublicTag:  "true",
+			},
+		},
+		{
+			name:       "forwarded-chain-disabled",
+			headers:    map[string]string{"X-Forwarded-For": "93.184.216.34, 10.0.0.1"},
+			remoteAddr: "10.0.0.3",
+			config:     &NetworkTagsConfig{ObfuscateChain: true},
+			expectedTags: map[string]string{
+				RemoteIPTag:        "10.0.0.3",
+				ClientIPTag:        "93.184.216.34",
+				ClientIPHeaderTag:  "x-forwarded-for",
+				ClientIPVersionTag: "4",
+				ClientIPPublicTag:  "true",
+			},
+		},
+		{
+			name:       "forwarded",
+			headers:    map[string]string{"Forwarded": `for="[2a00:1450:4007:80e::200e]:4711";proto=https, for=10.0.0.1`},
+			remoteAddr: "10.0.0.3",
+			expectedTags: map[string]string{
+				RemoteIPTag:        "10.0.0.3",
+				ClientIPTag:        "2a00:1450:4007:80e::200e",
+				ClientIPHeaderTag:  "forwarded",
+				ClientIPChainTag:   "2a00:1450:4007:80e::200e,private",
+				ClientIPVersionTag: "6",
+				ClientIPPublicTag:  "true",
+			},
+		},
+		{
+			name:       "private-header-ip",
+			headers:    map[string]string{"X-Real-Ip": "10.0.0.1"},
+			remoteAddr: "10.0.0.3",
+			expectedTags: map[string]string{
+				RemoteIPTag:        "10.0.0.3",
+				ClientIPTag:        "10.0.0.1",
+				ClientIPHeaderTag:  "x-real-ip",
+				ClientIPChainTag:   "private",
+				ClientIPVersionTag: "4",
+				ClientIPPublicTag:  "false",
+			},
+		},
+		{
+			name:       "public-remote-ip",
+			headers:    map[string]string{"X-Real-Ip": "10.0.0.1"},
+			remoteAddr: "93.184.216.34",
+			expectedTags: map[string]string{
+				RemoteIPTag:        "93.184.216.34",
+				ClientIPTag:        "93.184.216.34",
+				ClientIPVersionTag: "4",
+				ClientIPPublicTag:  "true",
+			},
+		},
+		{
+			name: "no-ip",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			headers := http.Header{}
+			for k, v := range tc.headers {
+				headers.Set(k, v)
+			}
+			cfg := defaultConfig
+			if tc.config != nil {
+				cfg = *tc.config
+			}
+
+			info := ResolveClientIP(headers, true, tc.remoteAddr, DefaultClientIPHeaders())
+			remoteIP, clientIP := ClientIP(headers, true, tc.remoteAddr, DefaultClientIPHeaders())
+			require.Equal(t, remoteIP, info.RemoteIP)
+			require.Equal(t, clientIP, info.ClientIP)
+
+			tags := NetworkTags(info, cfg)
+			if len(tc.expectedTags) == 0 {
+				require.Empty(t, tags)
+			} else {
+				require.Equal(t, tc.expectedTags, tags)
+			}
+		})
+	}
+}
+
+func TestNetworkTagsConfigFromEnv(t *testing.T) {
+	t.Setenv(EnvClientIPChainMaxLength, "8")
+	t.Setenv(EnvClientIPChainObfuscation, "false")
+	require.Equal(t, NetworkTagsConfig{MaxChainLength: 8}, NetworkTagsConfigFromEnv())
+}
+
+func TestForwardedChain(t *testing.T) {
+	cfg := NetworkTagsConfig{MaxChainLength: 2, ObfuscateChain: true}
+
+	t.Run("exact-length", func(t *testing.T) {
+		require.Equal(t, "8.8.8.8,private", forwardedChain("x-forwarded-for", []string{"8.8.8.8, 10.0.0.1"}, cfg))
+	})
+
+	t.Run("multiple-values", func(t *testing.T) {
+		require.Equal(t, "...,8.8.8.8,93.184.216.34", forwardedChain("x-forwarded-for", []string{"1.1.1.1, 8.8.8.8", "93.184.216.34"}, cfg))
+	})
+
+	t.Run("bounded-allocations", func(t *testing.T) {
+		// The cost of the chain does not depend on the size of the header
+		shortHeader := []string{strings.Repeat("8.8.8.8,", 3) + "8.8.4.4"}
+		longHeader := []string{strings.Repeat("8.8.8.8,", 10_000) + "8.8.4.4"}
+		shortAllocs := testing.AllocsPerRun(10, func() { forwardedChain("x-forwarded-for", shortHeader, cfg) })
+		longAllocs := testing.AllocsPerRun(10, func() { forwardedChain("x-forwarded-for", longHeader, cfg) })
+		require.Equal(t, shortAllocs, longAllocs)
+	})
+}
@@ -0,0 +1,76 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+// Package env provides the helpers used to read the configuration env vars, so
+// that they are parsed and their unexpected values logged the same way by all
+// the packages.
+package env
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/DataDog/appsec-internal-go/log"
+)
+
+// Bool returns the boolean value of the env var with the given key, or def if
+// it is not set or cannot be parsed.
+func Bool(key string, def bool) bool {
+	strVal, ok := os.LookupEnv(key)
+	if !ok {
+		return def
+	}
+	v, err := strconv.ParseBool(strVal)
+	if err != nil {
+		LogParsingError(key, strVal, err, def)
+		return def
+	}
+	return v
+}
+
+// Duration returns the duration value of the env var with the given key,
+// expressed in the given unit when it has none (e.g. `s`), or def if it is not
+// set or cannot be parsed.
+func Duration(key string, unit string, def time.Duration) time.Duration {
+	strVal, ok := os.LookupEnv(key)
+	if !ok {
+		return def
+	}
+	val, err := time.ParseDuration(strVal + unit)
+	if err != nil {
+		LogParsingError(key, strVal, err, def)
+		return def
+	}
+	return val
+}
+
+// Int returns the integer value of the env var with the given key, or def if
+// it is not set or cannot be parsed.
+func Int(key string, def int) int {
+	strVal, ok := os.LookupEnv(key)
+	if !ok {
+		return def
+	}
+	val, err := strconv.Atoi(strVal)
+	if err != nil {
+		LogParsingError(key, strVal, err, def)
+		return def
+	}
+	return val
+}
+
+// LogParsingError logs that the value of the env var with the given name could
+// not be parsed, and that the given default value is used instead.
+func LogParsingError(name, value string, err error, defaultValue any) {
+	log.Debug("appsec: could not parse the env var %s=%s: %v. Using default value %v.", name, value, err, defaultValue)
+}
+
+// LogUnexpectedValue logs that the value of the env var with the given name is
+// not expected for the given reason, and that the given default value is used
+// instead.
+func LogUnexpectedValue(name string, value any, reason string, defaultValue any) {
+	log.Debug("appsec: unexpected configuration value of %s=%v: %s. Using default value %v.", name, value, reason, defaultValue)
+}
@@ -0,0 +1,47 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023-present Datadog, Inc.
+
+package env
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDuration(t *testing.T) {
+	const varName = "DD_TEST_VARIABLE_DURATION"
+
+	type testCase struct {
+		EnvVal   string
+		EnvUnit  string
+		Expected time.Duration
+	}
+	testCases := map[string]testCase{
+		"blank": {
+			EnvVal:   "",
+			EnvUnit:  "s",
+			Expected: 1337,
+		},
+		"1m": {
+			EnvVal:   "1",
+			EnvUnit:  "m",
+			Expected: time.Minute,
+		},
+		"invalid": {
+			EnvVal:   "invalid",
+			EnvUnit:  "s",
+			Expected: 1337,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(varName, tc.EnvVal)
+			require.Equal(t, tc.Expected, Duration(varName, tc.EnvUnit, 1337))
+		})
+	}
+}
@@ -0,0 +1,72 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+package netip
+
+var (
+	// nat64WellKnownPrefix is the RFC 6052 well-known prefix used to represent
+	// IPv4 addresses in IPv6 for IPv4/IPv6 translation.
+	nat64WellKnownPrefix = MustParsePrefix("64:ff9b::/96")
+	// nat64LocalUsePrefix is the RFC 8215 local-use prefix used for IPv4/IPv6
+	// translation, using the RFC 6052 /48 address format.
+	nat64LocalUsePrefix = MustParsePrefix("64:ff9b:1::/48")
+	// sixToFourPrefix is the RFC 3056 6to4 prefix.
+	sixToFourPrefix = MustParsePrefix("2002::/16")
+	// teredoPrefix is the RFC 4380 Teredo prefix.
+	teredoPrefix = MustParsePrefix("2001::/32")
+)
+
+// EmbeddedIPv4 returns the IPv4 address embedded in the given IPv6 address, if
+// any. The following IPv6 address formats are supported:
+//   - IPv4-mapped addresses (`::ffff:0:0/96`), as per RFC 4291,
+//   - IPv4/IPv6 translation addresses (`64:ff9b::/96` and `64:ff9b:1::/48`), as
+//     per RFC 6052 and RFC 8215,
+//   - 6to4 addresses (`2002::/16`), as per RFC 3056,
+//   - Teredo addresses (`2001::/32`), returning the client's public IPv4 address,
+//     as per RFC 4380.
+func EmbeddedIPv4(ip Addr) (Addr, bool) {
+	if !ip.Is6() {
+		return Addr{}, false
+	}
+	if ip.Is4In6() {
+		return ip.Unmap(), true
+	}
+
+	ip = ip.WithZone("")
+	a := ip.As16()
+	switch {
+	case nat64WellKnownPrefix.Contains(ip):
+		return IPv4(a[12], a[13], a[14], a[15]), true
+	case nat64LocalUsePrefix.Contains(ip):
+		// Bits 64 to 71 are reserved, so the IPv4 address is split around them
+		return IPv4(a[6], a[7], a[9], a[10]), true
+	case sixToFourPrefix.Contains(ip):
+		return IPv4(a[2], a[3], a[4], a[5]), true
+	case teredoPrefix.Contains(ip):
+		// The client address is stored with all of its bits inverted
+		return IPv4(^a[12], ^a[13], ^a[14], ^a[15]), true
+	default:
+		return Addr{}, false
+	}
+}
+
+// Normalize returns the IPv4 address represented by the given IPv4-mapped or
+// IPv4/IPv6 translation IPv6 address, so that the same host is always reported
+// the same way, and is consistently matched against IPv4 address lists. Other
+// addresses are returned unchanged, including 6to4 and Teredo addresses which
+// are the actual IPv6 addresses of hosts tunneling over IPv4.
+func Normalize(ip Addr) Addr {
+	if !ip.Is6() {
+		return ip
+	}
+	if ip.Is4In6() {
+		return ip.Unmap()
+	}
+	if unzoned := ip.WithZone(""); nat64WellKnownPrefix.Contains(unzoned) || nat64LocalUsePrefix.Contains(unzoned) {
+		ipv4, _ := EmbeddedIPv4(unzoned)
+		return ipv4
+	}
+	return ip
+}
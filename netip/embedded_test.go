@@ -0,0 +1,81 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+package netip
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedIPv4(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		ip         string
+		embedded   string
+		normalized string
+	}{
+		{
+			name:       "ipv4",
+			ip:         "203.0.113.5",
+			normalized: "203.0.113.5",
+		},
+		{
+			name:       "ipv6",
+			ip:         "2a00:1450:4007:80e::200e",
+			normalized: "2a00:1450:4007:80e::200e",
+		},
+		{
+			name:       "ipv4-mapped",
+			ip:         "::ffff:203.0.113.5",
+			embedded:   "203.0.113.5",
+			normalized: "203.0.113.5",
+		},
+		{
+			name:       "nat64-well-known",
+			ip:         "64:ff9b::cb00:7105",
+			embedded:   "203.0.113.5",
+			normalized: "203.0.113.5",
+		},
+		{
+			name:       "nat64-local-use",
+			ip:         "64:ff9b:1:cb00:71:0500::",
+			embedded:   "203.0.113.5",
+			normalized: "203.0.113.5",
+		},
+		{
+			name:       "6to4",
+			ip:         "2002:cb00:7105::1",
+			embedded:   "203.0.113.5",
+			normalized: "2002:cb00:7105::1",
+		},
+		{
+			name:       "teredo",
+			ip:         "2001:0:4136:e378:8000:63bf:34ff:8efa",
+			embedded:   "203.0.113.5",
+			normalized: "2001:0:4136:e378:8000:63bf:34ff:8efa",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ip := MustParseAddr(tc.ip)
+			embedded, found := EmbeddedIPv4(ip)
+			if tc.embedded == "" {
+				require.False(t, found)
+				require.False(t, embedded.IsValid())
+			} else {
+				require.True(t, found)
+				require.Equal(t, MustParseAddr(tc.embedded), embedded)
+			}
+			require.Equal(t, MustParseAddr(tc.normalized), Normalize(ip))
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		_, found := EmbeddedIPv4(Addr{})
+		require.False(t, found)
+		require.Equal(t, Addr{}, Normalize(Addr{}))
+	})
+}
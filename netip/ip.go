@@ -16,8 +16,12 @@ type Prefix = netip.Prefix
 var (
 	// ParseAddr wraps the netip.ParseAddr function
 	ParseAddr = netip.ParseAddr
+	// ParsePrefix wraps the netip.ParsePrefix function
+	ParsePrefix = netip.ParsePrefix
 	// MustParsePrefix wraps the netip.MustParsePrefix function
 	MustParsePrefix = netip.MustParsePrefix
+	// PrefixFrom wraps the netip.PrefixFrom function
+	PrefixFrom = netip.PrefixFrom
 	// MustParseAddr wraps the netip.MustParseAddr function
 	MustParseAddr = netip.MustParseAddr
 	// AddrFrom16 wraps the netIP.AddrFrom16 function
@@ -0,0 +1,422 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+package netip
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/bits"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+type (
+	// PrefixSet is a set of IPv4 and IPv6 prefixes supporting fast lookups of
+	// the prefixes containing a given IP address. It is backed by a
+	// path-compressed binary radix tree (a.k.a. PATRICIA trie) per address
+	// family.
+	//
+	// Lookups are lock-less and can be performed concurrently with updates:
+	// the trees are never modified in place, instead updates copy the path from
+	// the root to the modified node and atomically replace the root (i.e,
+	// Copy-Update-Replace semantics). Updates are serialized with a mutex. The
+	// zero value is an empty set ready to use.
+	PrefixSet struct {
+		// root is the pointer to the current roots of the trees.
+		root atomic.Pointer[prefixSetRoot]
+		// mu serializes updates.
+		mu sync.Mutex
+	}
+
+	// prefixSetRoot holds the roots of the IPv4 and IPv6 trees of a
+	// [PrefixSet], along with the number of prefixes they contain.
+	prefixSetRoot struct {
+		// v4 is the root of the IPv4 prefixes tree.
+		v4 *prefixNode
+		// v6 is the root of the IPv6 prefixes tree.
+		v6 *prefixNode
+		// len is the number of prefixes in the set.
+		len int
+	}
+
+	// prefixNode is an immutable node of a path-compressed binary radix tree.
+	// A node either holds a prefix of the set, or is an intermediate node
+	// branching into two sub-trees.
+	prefixNode struct {
+		// key holds the bits of the node's prefix, left-aligned and masked.
+		key uint128
+		// bits is the length of the node's prefix.
+		bits int
+		// prefix is the prefix of the set held by this node, if isSet is true.
+		prefix Prefix
+		// isSet is true when this node holds a prefix of the set.
+		isSet bool
+		// children are the sub-trees of this node, indexed by the value of the
+		// bit following the node's prefix.
+		children [2]*prefixNode
+	}
+
+	// uint128 is a 128-bit unsigned integer, used to hold the bits of an
+	// address or prefix left-aligned (IPv4 addresses only use the 32 most
+	// significant bits).
+	uint128 struct {
+		hi uint64
+		lo uint64
+	}
+)
+
+// NewPrefixSet returns a new [PrefixSet] containing the given prefixes.
+func NewPrefixSet(prefixes ...Prefix) *PrefixSet {
+	set := &PrefixSet{}
+	set.Insert(prefixes...)
+	return set
+}
+
+// Len returns the number of prefixes in the set.
+func (s *PrefixSet) Len() int {
+	if root := s.root.Load(); root != nil {
+		return root.len
+	}
+	return 0
+}
+
+// Insert adds the given prefixes to the set, and returns the number of
+// prefixes that were not already present. Prefixes are masked before being
+// inserted, IPv4-mapped IPv6 prefixes are inserted as the IPv4 prefix they map,
+// and invalid prefixes are ignored.
+func (s *PrefixSet) Insert(prefixes ...Prefix) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	root := s.loadRoot()
+	added := 0
+	for _, p := range prefixes {
+		if p = normalizePrefix(p); !p.IsValid() {
+			continue
+		}
+		key, length := prefixKey(p)
+		tree := root.tree(p.Addr())
+		newTree, changed := tree.insert(key, length, p)
+		if !changed {
+			continue
+		}
+		root = root.withTree(p.Addr(), newTree, root.len+1)
+		added++
+	}
+	if added > 0 {
+		s.root.Store(root)
+	}
+	return added
+}
+
+// Remove removes the given prefixes from the set, and returns the number of
+// prefixes that were present. Prefixes are normalized the same way as with
+// [PrefixSet.Insert] before being removed.
+func (s *PrefixSet) Remove(prefixes ...Prefix) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	root := s.loadRoot()
+	removed := 0
+	for _, p := range prefixes {
+		if p = normalizePrefix(p); !p.IsValid() {
+			continue
+		}
+		key, length := prefixKey(p)
+		tree := root.tree(p.Addr())
+		newTree, changed := tree.remove(key, length)
+		if !changed {
+			continue
+		}
+		root = root.withTree(p.Addr(), newTree, root.len-1)
+		removed++
+	}
+	if removed > 0 {
+		s.root.Store(root)
+	}
+	return removed
+}
+
+// Contains returns true if the given IP address is contained in at least one
+// prefix of the set. IPv4-mapped IPv6 addresses are looked up as the IPv4
+// address they map.
+func (s *PrefixSet) Contains(ip Addr) bool {
+	_, found := s.lookup(ip, false)
+	return found
+}
+
+// LongestMatch returns the most specific prefix of the set containing the
+// given IP address, if any. IPv4-mapped IPv6 addresses are looked up as the
+// IPv4 address they map.
+func (s *PrefixSet) LongestMatch(ip Addr) (Prefix, bool) {
+	return s.lookup(ip, true)
+}
+
+// Prefixes returns the prefixes of the set, IPv4 prefixes first, in ascending
+// order of address then length.
+func (s *PrefixSet) Prefixes() []Prefix {
+	root := s.root.Load()
+	if root == nil {
+		return nil
+	}
+	prefixes := make([]Prefix, 0, root.len)
+	prefixes = root.v4.appendPrefixes(prefixes)
+	prefixes = root.v6.appendPrefixes(prefixes)
+	return prefixes
+}
+
+// lookup walks down the tree matching the given IP address, and returns the
+// first matching prefix, or the last (most specific) one if longest is true.
+func (s *PrefixSet) lookup(ip Addr, longest bool) (match Prefix, found bool) {
+	root := s.root.Load()
+	if root == nil || !ip.IsValid() {
+		return Prefix{}, false
+	}
+	ip = ip.Unmap()
+	key := addrKey(ip)
+	for node := root.tree(ip); node != nil && node.matches(key); node = node.children[key.bit(node.bits)] {
+		if node.isSet {
+			match, found = node.prefix, true
+			if !longest {
+				break
+			}
+		}
+		if node.bits == ip.BitLen() {
+			break
+		}
+	}
+	return match, found
+}
+
+// loadRoot returns the current root, or an empty one if the set has never been
+// updated.
+func (s *PrefixSet) loadRoot() *prefixSetRoot {
+	if root := s.root.Load(); root != nil {
+		return root
+	}
+	return &prefixSetRoot{}
+}
+
+// tree returns the root of the tree for the address family of the given IP
+// address.
+func (r *prefixSetRoot) tree(ip Addr) *prefixNode {
+	if ip.Is4() {
+		return r.v4
+	}
+	return r.v6
+}
+
+// withTree returns a copy of the receiver with the tree for the address family
+// of the given IP address replaced by the given one.
+func (r *prefixSetRoot) withTree(ip Addr, tree *prefixNode, count int) *prefixSetRoot {
+	res := *r
+	if ip.Is4() {
+		res.v4 = tree
+	} else {
+		res.v6 = tree
+	}
+	res.len = count
+	return &res
+}
+
+// insert returns a copy of the tree rooted at the receiver with the given
+// prefix added, and whether the tree has changed. Only the nodes on the path to
+// the inserted prefix are copied.
+func (n *prefixNode) insert(key uint128, bits int, prefix Prefix) (*prefixNode, bool) {
+	if n == nil {
+		return &prefixNode{key: key, bits: bits, prefix: prefix, isSet: true}, true
+	}
+
+	common := min(key.commonPrefixLen(n.key), bits, n.bits)
+	switch {
+	case common == n.bits && common == bits:
+		// This node is the prefix being inserted
+		if n.isSet {
+			return n, false
+		}
+		res := *n
+		res.prefix, res.isSet = prefix, true
+		return &res, true
+	case common == n.bits:
+		// The prefix being inserted belongs to one of this node's sub-trees
+		bit := key.bit(n.bits)
+		child, changed := n.children[bit].insert(key, bits, prefix)
+		if !changed {
+			return n, false
+		}
+		res := *n
+		res.children[bit] = child
+		return &res, true
+	case common == bits:
+		// The prefix being inserted is a parent of this node
+		res := &prefixNode{key: key, bits: bits, prefix: prefix, isSet: true}
+		res.children[n.key.bit(bits)] = n
+		return res, true
+	default:
+		// The prefix being inserted and this node diverge, so they need a new
+		// intermediate parent node
+		res := &prefixNode{key: key.masked(common), bits: common}
+		res.children[n.key.bit(common)] = n
+		res.children[key.bit(common)] = &prefixNode{key: key, bits: bits, prefix: prefix, isSet: true}
+		return res, true
+	}
+}
+
+// remove returns a copy of the tree rooted at the receiver with the given
+// prefix removed, and whether the tree has changed. Only the nodes on the path
+// to the removed prefix are copied, and intermediate nodes left with a single
+// child are removed.
+func (n *prefixNode) remove(key uint128, bits int) (*prefixNode, bool) {
+	if n == nil || n.bits > bits || !n.matches(key) {
+		return n, false
+	}
+
+	res := *n
+	if n.bits == bits {
+		if !n.isSet {
+			return n, false
+		}
+		res.prefix, res.isSet = Prefix{}, false
+	} else {
+		bit := key.bit(n.bits)
+		child, changed := n.children[bit].remove(key, bits)
+		if !changed {
+			return n, false
+		}
+		res.children[bit] = child
+	}
+
+	if res.isSet {
+		return &res, true
+	}
+	switch {
+	case res.children[0] == nil:
+		return res.children[1], true
+	case res.children[1] == nil:
+		return res.children[0], true
+	default:
+		return &res, true
+	}
+}
+
+// matches returns true if the given key starts with this node's prefix.
+func (n *prefixNode) matches(key uint128) bool {
+	return key.masked(n.bits) == n.key
+}
+
+// appendPrefixes appends the prefixes of the tree rooted at the receiver to the
+// given slice, in ascending order.
+func (n *prefixNode) appendPrefixes(prefixes []Prefix) []Prefix {
+	if n == nil {
+		return prefixes
+	}
+	if n.isSet {
+		prefixes = append(prefixes, n.prefix)
+	}
+	prefixes = n.children[0].appendPrefixes(prefixes)
+	return n.children[1].appendPrefixes(prefixes)
+}
+
+// normalizePrefix returns the masked version of the given prefix, converting
+// IPv4-mapped IPv6 prefixes into the IPv4 prefix they map, so that they match
+// IPv4 addresses.
+func normalizePrefix(p Prefix) Prefix {
+	if !p.IsValid() {
+		return Prefix{}
+	}
+	if p.Addr().Is4In6() && p.Bits() >= 96 {
+		p = PrefixFrom(p.Addr().Unmap(), p.Bits()-96)
+	}
+	return p.Masked()
+}
+
+// addrKey returns the left-aligned bits of the given IP address.
+func addrKey(ip Addr) uint128 {
+	if ip.Is4() {
+		a := ip.As4()
+		return uint128{hi: uint64(a[0])<<56 | uint64(a[1])<<48 | uint64(a[2])<<40 | uint64(a[3])<<32}
+	}
+	a := ip.As16()
+	return uint128{
+		hi: uint64(a[0])<<56 | uint64(a[1])<<48 | uint64(a[2])<<40 | uint64(a[3])<<32 |
+			uint64(a[4])<<24 | uint64(a[5])<<16 | uint64(a[6])<<8 | uint64(a[7]),
+		lo: uint64(a[8])<<56 | uint64(a[9])<<48 | uint64(a[10])<<40 | uint64(a[11])<<32 |
+			uint64(a[12])<<24 | uint64(a[13])<<16 | uint64(a[14])<<8 | uint64(a[15]),
+	}
+}
+
+// prefixKey returns the left-aligned bits of the given masked prefix, along
+// with its length.
+func prefixKey(p Prefix) (uint128, int) {
+	return addrKey(p.Addr()), p.Bits()
+}
+
+// bit returns the value of the i-th most significant bit.
+func (u uint128) bit(i int) int {
+	if i < 64 {
+		return int(u.hi>>(63-i)) & 1
+	}
+	return int(u.lo>>(127-i)) & 1
+}
+
+// masked returns a copy of the receiver with only its n most significant bits
+// kept.
+func (u uint128) masked(n int) uint128 {
+	switch {
+	case n <= 0:
+		return uint128{}
+	case n < 64:
+		return uint128{hi: u.hi & ^(^uint64(0) >> n)}
+	case n < 128:
+		return uint128{hi: u.hi, lo: u.lo & ^(^uint64(0) >> (n - 64))}
+	default:
+		return u
+	}
+}
+
+// commonPrefixLen returns the number of most significant bits shared by the
+// receiver and the other value.
+func (u uint128) commonPrefixLen(other uint128) int {
+	if n := bits.LeadingZeros64(u.hi ^ other.hi); n < 64 {
+		return n
+	}
+	return 64 + bits.LeadingZeros64(u.lo^other.lo)
+}
+
+// ReadPrefixSet reads a [PrefixSet] from the given reader, expecting one prefix
+// (e.g. `192.0.2.0/24`) or IP address per line, as is the format of the IP
+// ranges lists published by most cloud and CDN providers. Blank lines and
+// comments starting with `#` are ignored.
+func ReadPrefixSet(r io.Reader) (*PrefixSet, error) {
+	var prefixes []Prefix
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		if text = strings.TrimSpace(text); text == "" {
+			continue
+		}
+
+		if strings.IndexByte(text, '/') < 0 {
+			ip, err := ParseAddr(text)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			prefixes = append(prefixes, PrefixFrom(ip, ip.BitLen()))
+			continue
+		}
+		prefix, err := ParsePrefix(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewPrefixSet(prefixes...), nil
+}
@@ -0,0 +1,252 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+package netip
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrefixSet(t *testing.T) {
+	t.Run("zero-value", func(t *testing.T) {
+		var set PrefixSet
+		require.Zero(t, set.Len())
+		require.False(t, set.Contains(MustParseAddr("10.0.0.1")))
+		_, found := set.LongestMatch(MustParseAddr("10.0.0.1"))
+		require.False(t, found)
+		require.Empty(t, set.Prefixes())
+		require.Zero(t, set.Remove(MustParsePrefix("10.0.0.0/8")))
+	})
+
+	t.Run("lookups", func(t *testing.T) {
+		set := NewPrefixSet(
+			MustParsePrefix("10.0.0.0/8"),
+			MustParsePrefix("10.1.0.0/16"),
+			MustParsePrefix("10.1.2.3/32"),
+			MustParsePrefix("192.168.0.0/16"),
+			MustParsePrefix("2001:db8::/32"),
+			MustParsePrefix("2001:db8:1::/48"),
+		)
+		require.Equal(t, 6, set.Len())
+
+		for _, tc := range []struct {
+			ip       string
+			expected string
+		}{
+			{ip: "10.0.0.1", expected: "10.0.0.0/8"},
+			{ip: "10.1.0.1", expected: "10.1.0.0/16"},
+			{ip: "10.1.2.3", expected: "10.1.2.3/32"},
+			{ip: "10.1.2.4", expected: "10.1.0.0/16"},
+			{ip: "::ffff:10.1.2.3", expected: "10.1.2.3/32"},
+			{ip: "192.168.42.1", expected: "192.168.0.0/16"},
+			{ip: "192.169.0.1"},
+			{ip: "11.0.0.1"},
+			{ip: "2001:db8::1", expected: "2001:db8::/32"},
+			{ip: "2001:db8:1::1", expected: "2001:db8:1::/48"},
+			{ip: "2001:db8:1::1%eth0", expected: "2001:db8:1::/48"},
+			{ip: "2001:db9::1"},
+			{ip: "::a01:203"}, // IPv4-compatible addresses are not IPv4 addresses
+		} {
+			t.Run(tc.ip, func(t *testing.T) {
+				ip := MustParseAddr(tc.ip)
+				match, found := set.LongestMatch(ip)
+				require.Equal(t, tc.expected != "", found)
+				require.Equal(t, tc.expected != "", set.Contains(ip))
+				if tc.expected != "" {
+					require.Equal(t, MustParsePrefix(tc.expected), match)
+				}
+			})
+		}
+	})
+
+	t.Run("insert-remove", func(t *testing.T) {
+		set := NewPrefixSet()
+		require.Equal(t, 2, set.Insert(MustParsePrefix("10.1.2.3/8"), MustParsePrefix("::ffff:192.168.0.0/112"), Prefix{}))
+		require.Equal(t, []Prefix{MustParsePrefix("10.0.0.0/8"), MustParsePrefix("192.168.0.0/16")}, set.Prefixes())
+		require.Zero(t, set.Insert(MustParsePrefix("10.0.0.0/8")))
+
+		require.Equal(t, 1, set.Insert(MustParsePrefix("0.0.0.0/0")))
+		require.True(t, set.Contains(MustParseAddr("8.8.8.8")))
+		require.False(t, set.Contains(MustParseAddr("2001:db8::1")))
+
+		require.Equal(t, 1, set.Remove(MustParsePrefix("0.0.0.0/0"), MustParsePrefix("10.0.0.0/16")))
+		require.False(t, set.Contains(MustParseAddr("8.8.8.8")))
+		require.Equal(t, 2, set.Len())
+
+		require.Equal(t, 2, set.Remove(MustParsePrefix("10.0.0.0/8"), MustParsePrefix("192.168.0.0/16")))
+		require.Zero(t, set.Len())
+		require.Empty(t, set.Prefixes())
+	})
+
+	t.Run("random", func(t *testing.T) {
+		rand := rand.New(rand.NewSource(1337))
+		prefixes := randPrefixes(rand, 10_000)
+		set := NewPrefixSet(prefixes...)
+
+		// Remove a third of the prefixes
+		expected := make(map[Prefix]struct{}, len(prefixes))
+		for i, p := range prefixes {
+			if i%3 == 0 {
+				require.Equal(t, 1, set.Remove(p))
+				continue
+			}
+			expected[p] = struct{}{}
+		}
+		require.Equal(t, len(expected), set.Len())
+		require.Len(t, set.Prefixes(), len(expected))
+
+		for range 2_000 {
+			ip := randAddr(rand)
+			match, found := set.LongestMatch(ip)
+
+			// Brute-force the longest match
+			var expectedMatch Prefix
+			for p := range expected {
+				if p.Contains(ip) && (!expectedMatch.IsValid() || p.Bits() > expectedMatch.Bits()) {
+					expectedMatch = p
+				}
+			}
+			require.Equal(t, expectedMatch.IsValid(), found)
+			require.Equal(t, expectedMatch, match)
+			require.Equal(t, found, set.Contains(ip))
+		}
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		set := NewPrefixSet(MustParsePrefix("10.0.0.0/8"))
+		stable := MustParseAddr("10.42.0.1")
+
+		var (
+			wg   sync.WaitGroup
+			done = make(chan struct{})
+		)
+		for range 4 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-done:
+						return
+					default:
+						// Updates never affect this lookup, which must never fail
+						assert.True(t, set.Contains(stable))
+					}
+				}
+			}()
+		}
+
+		rand := rand.New(rand.NewSource(1337))
+		for _, p := range randPrefixes(rand, 1_000) {
+			set.Insert(p)
+			set.Remove(p)
+		}
+		close(done)
+		wg.Wait()
+		require.Equal(t, 1, set.Len())
+	})
+}
+
+func TestReadPrefixSet(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		set, err := ReadPrefixSet(strings.NewReader(`# Provider ranges
+173.245.48.0/20
+103.21.244.0/22 # trailing comment
+
+2400:cb00::/32
+192.0.2.1
+`))
+		require.NoError(t, err)
+		require.Equal(t, []Prefix{
+			MustParsePrefix("103.21.244.0/22"),
+			MustParsePrefix("173.245.48.0/20"),
+			MustParsePrefix("192.0.2.1/32"),
+			MustParsePrefix("2400:cb00::/32"),
+		}, set.Prefixes())
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		set, err := ReadPrefixSet(strings.NewReader(""))
+		require.NoError(t, err)
+		require.Zero(t, set.Len())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := ReadPrefixSet(strings.NewReader("10.0.0.0/8\n10.0.0.0/33\n"))
+		require.ErrorContains(t, err, "line 2")
+		_, err = ReadPrefixSet(strings.NewReader("not an ip\n"))
+		require.ErrorContains(t, err, "line 1")
+	})
+}
+
+func BenchmarkPrefixSet(b *testing.B) {
+	for _, size := range []int{1_000, 100_000, 1_000_000} {
+		rand := rand.New(rand.NewSource(1337))
+		prefixes := randPrefixes(rand, size)
+		set := NewPrefixSet(prefixes...)
+		ips := make([]Addr, 1_024)
+		for i := range ips {
+			ips[i] = randAddr(rand)
+		}
+
+		b.Run(fmt.Sprintf("size=%d/LongestMatch", size), func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for i := 0; pb.Next(); i = (i + 1) % len(ips) {
+					_, _ = set.LongestMatch(ips[i])
+				}
+			})
+		})
+
+		b.Run(fmt.Sprintf("size=%d/Insert", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				p := prefixes[i%len(prefixes)]
+				set.Remove(p)
+				set.Insert(p)
+			}
+		})
+	}
+}
+
+// randPrefixes returns count random prefixes, evenly split between IPv4 and
+// IPv6, with lengths typical of network ranges lists.
+func randPrefixes(rand *rand.Rand, count int) []Prefix {
+	seen := make(map[Prefix]struct{}, count)
+	prefixes := make([]Prefix, 0, count)
+	for len(prefixes) < count {
+		ip := randAddr(rand)
+		bits := 8 + rand.Intn(ip.BitLen()-7)
+		if ip.Is6() {
+			bits = 16 + rand.Intn(49)
+		}
+		p := PrefixFrom(ip, bits).Masked()
+		if _, found := seen[p]; found {
+			continue
+		}
+		seen[p] = struct{}{}
+		prefixes = append(prefixes, p)
+	}
+	return prefixes
+}
+
+// randAddr returns a random IPv4 or IPv6 address. IPv6 addresses share a common
+// prefix so that lookups hit the random prefixes.
+func randAddr(rand *rand.Rand) Addr {
+	if rand.Intn(2) == 0 {
+		return IPv4(byte(rand.Uint32()), byte(rand.Uint32()), byte(rand.Uint32()), byte(rand.Uint32()))
+	}
+	var a [16]byte
+	_, _ = rand.Read(a[:])
+	a[0], a[1] = 0x20, 0x01
+	return AddrFrom16(a)
+}
@@ -0,0 +1,137 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+package netip
+
+// SpecialPurposeRange is an entry of the IANA IPv4 and IPv6 Special-Purpose
+// Address Registries.
+// See https://www.iana.org/assignments/iana-ipv4-special-registry and
+// https://www.iana.org/assignments/iana-ipv6-special-registry
+type SpecialPurposeRange struct {
+	// Prefix is the address block of the range.
+	Prefix Prefix
+	// Name is the name of the range, as listed in the registry.
+	Name string
+	// RFC is the reference defining the range.
+	RFC string
+	// Global reports whether the addresses of the range are globally
+	// reachable, as listed in the registry. Ranges whose reachability is not
+	// applicable (6to4 and Teredo), as well as the NAT64 well-known prefix, are
+	// not considered globally reachable, since their addresses embed the IPv4
+	// address of another host.
+	Global bool
+}
+
+// specialPurposeRanges is the list of special-purpose address ranges. On top of
+// the IANA registries, it includes the multicast and deprecated IPv6 site-local
+// ranges, which are not globally reachable unicast addresses either.
+var specialPurposeRanges = [...]SpecialPurposeRange{
+	// IPv4
+	{Prefix: MustParsePrefix("0.0.0.0/8"), Name: "This network", RFC: "RFC 791"},
+	{Prefix: MustParsePrefix("0.0.0.0/32"), Name: "This host on this network", RFC: "RFC 1122"},
+	{Prefix: MustParsePrefix("10.0.0.0/8"), Name: "Private-Use", RFC: "RFC 1918"},
+	{Prefix: MustParsePrefix("100.64.0.0/10"), Name: "Shared Address Space", RFC: "RFC 6598"},
+	{Prefix: MustParsePrefix("127.0.0.0/8"), Name: "Loopback", RFC: "RFC 1122"},
+	{Prefix: MustParsePrefix("169.254.0.0/16"), Name: "Link Local", RFC: "RFC 3927"},
+	{Prefix: MustParsePrefix("172.16.0.0/12"), Name: "Private-Use", RFC: "RFC 1918"},
+	{Prefix: MustParsePrefix("192.0.0.0/24"), Name: "IETF Protocol Assignments", RFC: "RFC 6890"},
+	{Prefix: MustParsePrefix("192.0.0.0/29"), Name: "IPv4 Service Continuity Prefix", RFC: "RFC 7335"},
+	{Prefix: MustParsePrefix("192.0.0.8/32"), Name: "IPv4 dummy address", RFC: "RFC 7600"},
+	{Prefix: MustParsePrefix("192.0.0.9/32"), Name: "Port Control Protocol Anycast", RFC: "RFC 7723", Global: true},
+	{Prefix: MustParsePrefix("192.0.0.10/32"), Name: "Traversal Using Relays around NAT Anycast", RFC: "RFC 8155", Global: true},
+	{Prefix: MustParsePrefix("192.0.0.170/32"), Name: "NAT64/DNS64 Discovery", RFC: "RFC 8880"},
+	{Prefix: MustParsePrefix("192.0.0.171/32"), Name: "NAT64/DNS64 Discovery", RFC: "RFC 8880"},
+	{Prefix: MustParsePrefix("192.0.2.0/24"), Name: "Documentation (TEST-NET-1)", RFC: "RFC 5737"},
+	{Prefix: MustParsePrefix("192.31.196.0/24"), Name: "AS112-v4", RFC: "RFC 7535", Global: true},
+	{Prefix: MustParsePrefix("192.52.193.0/24"), Name: "AMT", RFC: "RFC 7450", Global: true},
+	{Prefix: MustParsePrefix("192.88.99.0/24"), Name: "Deprecated (6to4 Relay Anycast)", RFC: "RFC 7526"},
+	{Prefix: MustParsePrefix("192.168.0.0/16"), Name: "Private-Use", RFC: "RFC 1918"},
+	{Prefix: MustParsePrefix("192.175.48.0/24"), Name: "Direct Delegation AS112 Service", RFC: "RFC 7534", Global: true},
+	{Prefix: MustParsePrefix("198.18.0.0/15"), Name: "Benchmarking", RFC: "RFC 2544"},
+	{Prefix: MustParsePrefix("198.51.100.0/24"), Name: "Documentation (TEST-NET-2)", RFC: "RFC 5737"},
+	{Prefix: MustParsePrefix("203.0.113.0/24"), Name: "Documentation (TEST-NET-3)", RFC: "RFC 5737"},
+	{Prefix: MustParsePrefix("224.0.0.0/4"), Name: "Multicast", RFC: "RFC 5771"},
+	{Prefix: MustParsePrefix("240.0.0.0/4"), Name: "Reserved", RFC: "RFC 1112"},
+	{Prefix: MustParsePrefix("255.255.255.255/32"), Name: "Limited Broadcast", RFC: "RFC 919"},
+
+	// IPv6
+	{Prefix: MustParsePrefix("::1/128"), Name: "Loopback Address", RFC: "RFC 4291"},
+	{Prefix: MustParsePrefix("::/128"), Name: "Unspecified Address", RFC: "RFC 4291"},
+	{Prefix: MustParsePrefix("::ffff:0:0/96"), Name: "IPv4-mapped Address", RFC: "RFC 4291"},
+	{Prefix: MustParsePrefix("64:ff9b::/96"), Name: "IPv4-IPv6 Translation", RFC: "RFC 6052"},
+	{Prefix: MustParsePrefix("64:ff9b:1::/48"), Name: "IPv4-IPv6 Translation", RFC: "RFC 8215"},
+	{Prefix: MustParsePrefix("100::/64"), Name: "Discard-Only Address Block", RFC: "RFC 6666"},
+	{Prefix: MustParsePrefix("100:0:0:1::/64"), Name: "Dummy IPv6 Prefix", RFC: "RFC 9780"},
+	{Prefix: MustParsePrefix("2001::/23"), Name: "IETF Protocol Assignments", RFC: "RFC 2928"},
+	{Prefix: MustParsePrefix("2001::/32"), Name: "TEREDO", RFC: "RFC 4380"},
+	{Prefix: MustParsePrefix("2001:1::1/128"), Name: "Port Control Protocol Anycast", RFC: "RFC 7723", Global: true},
+	{Prefix: MustParsePrefix("2001:1::2/128"), Name: "Traversal Using Relays around NAT Anycast", RFC: "RFC 8155", Global: true},
+	{Prefix: MustParsePrefix("2001:1::3/128"), Name: "DNS-SD Service Registration Protocol Anycast", RFC: "RFC 9665", Global: true},
+	{Prefix: MustParsePrefix("2001:2::/48"), Name: "Benchmarking", RFC: "RFC 5180"},
+	{Prefix: MustParsePrefix("2001:3::/32"), Name: "AMT", RFC: "RFC 7450", Global: true},
+	{Prefix: MustParsePrefix("2001:4:112::/48"), Name: "AS112-v6", RFC: "RFC 7535", Global: true},
+	{Prefix: MustParsePrefix("2001:10::/28"), Name: "Deprecated (previously ORCHID)", RFC: "RFC 4843"},
+	{Prefix: MustParsePrefix("2001:20::/28"), Name: "ORCHIDv2", RFC: "RFC 7343", Global: true},
+	{Prefix: MustParsePrefix("2001:30::/28"), Name: "Drone Remote ID Protocol Entity Tags (DETs) Prefix", RFC: "RFC 9374", Global: true},
+	{Prefix: MustParsePrefix("2001:db8::/32"), Name: "Documentation", RFC: "RFC 3849"},
+	{Prefix: MustParsePrefix("2002::/16"), Name: "6to4", RFC: "RFC 3056"},
+	{Prefix: MustParsePrefix("2620:4f:8000::/48"), Name: "Direct Delegation AS112 Service", RFC: "RFC 7534", Global: true},
+	{Prefix: MustParsePrefix("3fff::/20"), Name: "Documentation", RFC: "RFC 9637"},
+	{Prefix: MustParsePrefix("5f00::/16"), Name: "Segment Routing (SRv6) SIDs", RFC: "RFC 9602"},
+	{Prefix: MustParsePrefix("fc00::/7"), Name: "Unique-Local", RFC: "RFC 4193"},
+	{Prefix: MustParsePrefix("fe80::/10"), Name: "Link-Local Unicast", RFC: "RFC 4291"},
+	{Prefix: MustParsePrefix("fec0::/10"), Name: "Site-Local (deprecated)", RFC: "RFC 3879"},
+	{Prefix: MustParsePrefix("ff00::/8"), Name: "Multicast", RFC: "RFC 4291"},
+}
+
+var (
+	// specialPurposeSet is the set of special-purpose address ranges prefixes,
+	// used to quickly find the range containing an address.
+	specialPurposeSet = NewPrefixSet()
+	// specialPurposeIndex maps the prefixes of specialPurposeSet to their
+	// index in specialPurposeRanges.
+	specialPurposeIndex = make(map[Prefix]int, len(specialPurposeRanges))
+	// ipv4MappedRange is the special-purpose range of IPv4-mapped IPv6
+	// addresses, which [PrefixSet] lookups treat as IPv4 addresses.
+	ipv4MappedRange SpecialPurposeRange
+)
+
+func init() {
+	for i, r := range specialPurposeRanges {
+		if r.Prefix.Addr().Is4In6() {
+			// Would be inserted as the 0.0.0.0/0 IPv4 prefix
+			ipv4MappedRange = r
+			continue
+		}
+		specialPurposeSet.Insert(r.Prefix)
+		specialPurposeIndex[r.Prefix] = i
+	}
+}
+
+// SpecialPurpose returns the most specific special-purpose address range
+// containing the given IP address, if any. IPv4-mapped IPv6 addresses belong to
+// the `::ffff:0:0/96` range, use [Addr.Unmap] first to classify them according
+// to the IPv4 address they map.
+func SpecialPurpose(ip Addr) (SpecialPurposeRange, bool) {
+	if ip.Is4In6() {
+		return ipv4MappedRange, true
+	}
+	prefix, found := specialPurposeSet.LongestMatch(ip)
+	if !found {
+		return SpecialPurposeRange{}, false
+	}
+	return specialPurposeRanges[specialPurposeIndex[prefix]], true
+}
+
+// IsGlobal reports whether the given IP address is a valid, globally reachable
+// unicast address. IPv4-mapped IPv6 addresses are classified according to the
+// IPv4 address they map.
+func IsGlobal(ip Addr) bool {
+	if !ip.IsValid() {
+		return false
+	}
+	r, found := SpecialPurpose(ip.Unmap())
+	return !found || r.Global
+}
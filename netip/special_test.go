@@ -0,0 +1,91 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2026-present Datadog, Inc.
+
+package netip
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpecialPurpose(t *testing.T) {
+	for _, tc := range []struct {
+		ip     string
+		name   string
+		global bool
+	}{
+		// IPv4
+		{ip: "8.8.8.8", global: true},
+		{ip: "0.0.0.0", name: "This host on this network"},
+		{ip: "0.1.2.3", name: "This network"},
+		{ip: "10.1.2.3", name: "Private-Use"},
+		{ip: "100.64.0.1", name: "Shared Address Space"},
+		{ip: "100.127.255.255", name: "Shared Address Space"},
+		{ip: "100.128.0.1", global: true},
+		{ip: "127.0.0.1", name: "Loopback"},
+		{ip: "169.254.1.2", name: "Link Local"},
+		{ip: "172.31.1.2", name: "Private-Use"},
+		{ip: "192.0.0.1", name: "IPv4 Service Continuity Prefix"},
+		{ip: "192.0.0.9", name: "Port Control Protocol Anycast", global: true},
+		{ip: "192.0.0.42", name: "IETF Protocol Assignments"},
+		{ip: "192.0.2.1", name: "Documentation (TEST-NET-1)"},
+		{ip: "192.88.99.1", name: "Deprecated (6to4 Relay Anycast)"},
+		{ip: "192.168.1.2", name: "Private-Use"},
+		{ip: "198.18.0.1", name: "Benchmarking"},
+		{ip: "198.19.255.255", name: "Benchmarking"},
+		{ip: "198.51.100.1", name: "Documentation (TEST-NET-2)"},
+		{ip: "203.0.113.5", name: "Documentation (TEST-NET-3)"},
+		{ip: "224.0.0.1", name: "Multicast"},
+		{ip: "240.0.0.1", name: "Reserved"},
+		{ip: "255.255.255.255", name: "Limited Broadcast"},
+
+		// IPv6
+		{ip: "2a00:1450:4007:80e::200e", global: true},
+		{ip: "::", name: "Unspecified Address"},
+		{ip: "::1", name: "Loopback Address"},
+		{ip: "::ffff:10.0.0.1", name: "IPv4-mapped Address"},
+		{ip: "64:ff9b::cb00:7105", name: "IPv4-IPv6 Translation"},
+		{ip: "64:ff9b:1::1", name: "IPv4-IPv6 Translation"},
+		{ip: "100::1", name: "Discard-Only Address Block"},
+		{ip: "2001::1", name: "TEREDO"},
+		{ip: "2001:1::1", name: "Port Control Protocol Anycast", global: true},
+		{ip: "2001:1::4", name: "IETF Protocol Assignments"},
+		{ip: "2001:2::1", name: "Benchmarking"},
+		{ip: "2001:20::1", name: "ORCHIDv2", global: true},
+		{ip: "2001:db8::1", name: "Documentation"},
+		{ip: "2002:cb00:7105::1", name: "6to4"},
+		{ip: "3fff::1", name: "Documentation"},
+		{ip: "fd00::1", name: "Unique-Local"},
+		{ip: "fe80::1", name: "Link-Local Unicast"},
+		{ip: "fe80::1%eth0", name: "Link-Local Unicast"},
+		{ip: "fec0::1", name: "Site-Local (deprecated)"},
+		{ip: "ff02::1", name: "Multicast"},
+	} {
+		t.Run(tc.ip, func(t *testing.T) {
+			ip := MustParseAddr(tc.ip)
+			r, found := SpecialPurpose(ip)
+			if tc.name == "" {
+				require.False(t, found)
+			} else {
+				require.True(t, found)
+				require.Equal(t, tc.name, r.Name)
+				require.True(t, r.Prefix.Contains(ip.WithZone("")))
+			}
+			require.Equal(t, tc.global, IsGlobal(ip))
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		_, found := SpecialPurpose(Addr{})
+		require.False(t, found)
+		require.False(t, IsGlobal(Addr{}))
+	})
+
+	t.Run("ipv4-mapped", func(t *testing.T) {
+		require.True(t, IsGlobal(MustParseAddr("::ffff:8.8.8.8")))
+		require.False(t, IsGlobal(MustParseAddr("::ffff:100.64.0.1")))
+	})
+}